@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// Config provides the sub-command to manage the configuration.
+func Config(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "manage the configuration",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "check",
+				Usage:  "validate the configuration of the server",
+				Flags:  serverFlags(cfg),
+				Before: configBefore(cfg),
+				Action: configCheck(cfg),
+			},
+		},
+	}
+}
+
+func configBefore(cfg *config.Config) cli.BeforeFunc {
+	return func(c *cli.Context) error {
+		if err := configFile(c, cfg, append(flags(cfg), serverFlags(cfg)...)); err != nil {
+			return err
+		}
+
+		return serverConfig(c, cfg)
+	}
+}
+
+func configCheck(cfg *config.Config) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		problems := config.Validate(cfg)
+
+		for i, rule := range cfg.Proxy.SkipAuth {
+			if _, err := policy.NewSkip([]config.SkipAuth{rule}); err != nil {
+				problems = append(problems, fmt.Errorf("proxy.skip_auth[%d]: %s", i, err))
+			}
+		}
+
+		if len(problems) == 0 {
+			fmt.Fprintln(os.Stdout, "configuration is valid")
+			return nil
+		}
+
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+
+		fmt.Fprintf(os.Stderr, "found %d problems\n", len(problems))
+		return errors.New("configuration is invalid")
+	}
+}
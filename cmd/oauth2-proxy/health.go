@@ -1,11 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"sort"
 
 	"github.com/rs/zerolog/log"
 	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/handler"
 	"gopkg.in/urfave/cli.v2"
 )
 
@@ -15,6 +19,7 @@ func Health(cfg *config.Config) *cli.Command {
 		Name:   "health",
 		Usage:  "perform health checks for service",
 		Flags:  healthFlags(cfg),
+		Before: healthBefore(cfg),
 		Action: healthAction(cfg),
 	}
 }
@@ -31,11 +36,17 @@ func healthFlags(cfg *config.Config) []cli.Flag {
 	}
 }
 
+func healthBefore(cfg *config.Config) cli.BeforeFunc {
+	return func(c *cli.Context) error {
+		return configFile(c, cfg, append(flags(cfg), healthFlags(cfg)...))
+	}
+}
+
 func healthAction(cfg *config.Config) cli.ActionFunc {
 	return func(c *cli.Context) error {
 		resp, err := http.Get(
 			fmt.Sprintf(
-				"http://%s/healthz",
+				"http://%s/readyz",
 				cfg.Server.Health,
 			),
 		)
@@ -50,7 +61,37 @@ func healthAction(cfg *config.Config) cli.ActionFunc {
 
 		defer resp.Body.Close()
 
-		if resp.StatusCode != 200 {
+		result := handler.Readiness{}
+
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			log.Error().
+				Err(err).
+				Msg("failed to parse health check")
+
+			return err
+		}
+
+		names := make([]string, 0, len(result.Checks))
+
+		for name := range result.Checks {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			check := result.Checks[name]
+
+			if check.Message != "" {
+				fmt.Fprintf(os.Stdout, "%s: %s (%s)\n", name, check.Status, check.Message)
+			} else {
+				fmt.Fprintf(os.Stdout, "%s: %s\n", name, check.Status)
+			}
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("health check returned status %d", resp.StatusCode)
+
 			log.Error().
 				Err(err).
 				Msg("health seems to be in a bad state")
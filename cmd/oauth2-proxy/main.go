@@ -57,6 +57,15 @@ func authors(cfg *config.Config) []*cli.Author {
 
 func flags(cfg *config.Config) []cli.Flag {
 	return []cli.Flag{
+		// config flags
+		&cli.StringFlag{
+			Name:        "config",
+			Value:       "",
+			Usage:       "path to yaml, toml or json config file",
+			EnvVars:     []string{"OAUTH2_PROXY_CONFIG"},
+			Destination: &cfg.File,
+		},
+
 		// logging flags
 		&cli.StringFlag{
 			Name:        "log-level",
@@ -84,39 +93,111 @@ func flags(cfg *config.Config) []cli.Flag {
 
 func before(cfg *config.Config) cli.BeforeFunc {
 	return func(c *cli.Context) error {
-		switch strings.ToLower(cfg.Logs.Level) {
-		case "debug":
-			zerolog.SetGlobalLevel(zerolog.DebugLevel)
-		case "info":
-			zerolog.SetGlobalLevel(zerolog.InfoLevel)
-		case "warn":
-			zerolog.SetGlobalLevel(zerolog.WarnLevel)
-		case "error":
-			zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-		case "fatal":
-			zerolog.SetGlobalLevel(zerolog.FatalLevel)
-		case "panic":
-			zerolog.SetGlobalLevel(zerolog.PanicLevel)
-		default:
-			zerolog.SetGlobalLevel(zerolog.InfoLevel)
+		if err := configFile(c, cfg, flags(cfg)); err != nil {
+			return err
 		}
 
-		if cfg.Logs.Pretty {
-			log.Logger = log.Output(
-				zerolog.ConsoleWriter{
-					Out:     os.Stderr,
-					NoColor: !cfg.Logs.Colored,
-				},
-			)
-		}
+		logging(cfg)
+		return nil
+	}
+}
 
+// logging applies the log level and output format.
+func logging(cfg *config.Config) {
+	switch strings.ToLower(cfg.Logs.Level) {
+	case "debug":
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	case "info":
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	case "warn":
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	case "error":
+		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	case "fatal":
+		zerolog.SetGlobalLevel(zerolog.FatalLevel)
+	case "panic":
+		zerolog.SetGlobalLevel(zerolog.PanicLevel)
+	default:
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	}
+
+	if cfg.Logs.Pretty {
+		log.Logger = log.Output(
+			zerolog.ConsoleWriter{
+				Out:     os.Stderr,
+				NoColor: !cfg.Logs.Colored,
+			},
+		)
+	}
+}
+
+// configFile loads the config file while keeping the values of flags which
+// have been set via command line or environment, resulting in a precedence
+// of file < env < flags.
+func configFile(c *cli.Context, cfg *config.Config, list []cli.Flag) error {
+	if cfg.File == "" {
 		return nil
 	}
+
+	restore := []func(){}
+
+	for _, f := range list {
+		switch f := f.(type) {
+		case *cli.StringFlag:
+			if dst, val := f.Destination, *f.Destination; explicit(c, f.Names(), f.EnvVars) {
+				restore = append(restore, func() { *dst = val })
+			}
+		case *cli.BoolFlag:
+			if dst, val := f.Destination, *f.Destination; explicit(c, f.Names(), f.EnvVars) {
+				restore = append(restore, func() { *dst = val })
+			}
+		case *cli.IntFlag:
+			if dst, val := f.Destination, *f.Destination; explicit(c, f.Names(), f.EnvVars) {
+				restore = append(restore, func() { *dst = val })
+			}
+		case *cli.DurationFlag:
+			if dst, val := f.Destination, *f.Destination; explicit(c, f.Names(), f.EnvVars) {
+				restore = append(restore, func() { *dst = val })
+			}
+		}
+	}
+
+	if err := config.Load(cfg.File, cfg); err != nil {
+		log.Error().
+			Err(err).
+			Str("file", cfg.File).
+			Msg("failed to load config file")
+
+		return err
+	}
+
+	for _, fn := range restore {
+		fn()
+	}
+
+	return nil
+}
+
+func explicit(c *cli.Context, names, envs []string) bool {
+	for _, name := range names {
+		if c.IsSet(name) {
+			return true
+		}
+	}
+
+	for _, env := range envs {
+		if _, ok := os.LookupEnv(env); ok {
+			return true
+		}
+	}
+
+	return false
 }
 
 func command(cfg *config.Config) []*cli.Command {
 	return []*cli.Command{
 		Server(cfg),
 		Health(cfg),
+		Config(cfg),
 	}
 }
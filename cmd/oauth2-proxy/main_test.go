@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"gopkg.in/urfave/cli.v2"
+)
+
+func TestConfigFilePrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+
+	if err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.yaml")
+	content := "logs:\n  level: warn\n  pretty: true\nsession:\n  lifetime: 2h\n"
+
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		level    string
+		lifetime time.Duration
+	}{
+		{
+			name:     "defaults",
+			args:     []string{},
+			level:    "info",
+			lifetime: 24 * time.Hour,
+		},
+		{
+			name:     "file",
+			args:     []string{"--config", file},
+			level:    "warn",
+			lifetime: 2 * time.Hour,
+		},
+		{
+			name:     "env over file",
+			args:     []string{"--config", file},
+			env:      map[string]string{"OAUTH2_PROXY_LOG_LEVEL": "error", "OAUTH2_PROXY_SESSION_LIFETIME": "3h"},
+			level:    "error",
+			lifetime: 3 * time.Hour,
+		},
+		{
+			name:     "flags over env",
+			args:     []string{"--config", file, "--log-level", "debug", "--session-lifetime", "4h"},
+			env:      map[string]string{"OAUTH2_PROXY_LOG_LEVEL": "error", "OAUTH2_PROXY_SESSION_LIFETIME": "3h"},
+			level:    "debug",
+			lifetime: 4 * time.Hour,
+		},
+		{
+			name:     "config from env",
+			args:     []string{"--log-level", "debug"},
+			env:      map[string]string{"OAUTH2_PROXY_CONFIG": file},
+			level:    "debug",
+			lifetime: 2 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, val := range tt.env {
+				os.Setenv(key, val)
+				defer os.Unsetenv(key)
+			}
+
+			cfg := config.New()
+
+			app := &cli.App{
+				Flags: append(flags(cfg), serverFlags(cfg)...),
+				Action: func(c *cli.Context) error {
+					return configFile(c, cfg, append(flags(cfg), serverFlags(cfg)...))
+				},
+			}
+
+			if err := app.Run(append([]string{"oauth2-proxy"}, tt.args...)); err != nil {
+				t.Fatalf("failed to run: %s", err)
+			}
+
+			if cfg.Logs.Level != tt.level {
+				t.Errorf("expected log level %q, got %q", tt.level, cfg.Logs.Level)
+			}
+
+			if cfg.Session.Lifetime != tt.lifetime {
+				t.Errorf("expected lifetime %s, got %s", tt.lifetime, cfg.Session.Lifetime)
+			}
+
+			if pretty := cfg.File != ""; cfg.Logs.Pretty != pretty {
+				t.Errorf("expected pretty logging %v from the file, got %v", pretty, cfg.Logs.Pretty)
+			}
+		})
+	}
+}
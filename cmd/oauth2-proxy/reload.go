@@ -0,0 +1,313 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+	"github.com/webhippie/oauth2-proxy/pkg/router"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+	"github.com/webhippie/oauth2-proxy/pkg/upstream"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var (
+	// restartKeys defines the config keys which can't be applied at runtime.
+	restartKeys = []string{
+		"server.health",
+		"server.secure",
+		"server.public",
+		"server.cert",
+		"server.key",
+		"server.auto_cert",
+		"server.strict_curves",
+		"server.strict_ciphers",
+		"server.storage",
+		"server.watch",
+		"server.trusted_proxies",
+		"session",
+		"jwt",
+		"audit",
+	}
+)
+
+// reloader swaps the configuration and the derived handlers at runtime,
+// the listeners stay open so in-flight connections don't get dropped.
+type reloader struct {
+	ctx      *cli.Context
+	cfg      *config.Config
+	storage  store.SessionStore
+	sessions *session.Manager
+	signer   *jwt.Signer
+	proxy    *upstream.Table
+	handler  *router.Reloadable
+	status   *router.Reloadable
+}
+
+// Run waits for SIGHUP or changes of the watched files to reload.
+func (r *reloader) Run(stop <-chan struct{}) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var (
+		events   <-chan fsnotify.Event
+		failures <-chan error
+		debounce <-chan time.Time
+	)
+
+	if r.cfg.Server.Watch {
+		watcher, err := fsnotify.NewWatcher()
+
+		if err != nil {
+			return err
+		}
+
+		defer watcher.Close()
+
+		for _, dir := range r.dirs() {
+			if err := watcher.Add(dir); err != nil {
+				log.Warn().
+					Err(err).
+					Str("dir", dir).
+					Msg("failed to watch config directory")
+			}
+		}
+
+		events = watcher.Events
+		failures = watcher.Errors
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-hup:
+			log.Info().
+				Msg("received hangup signal, reloading")
+
+			r.Reload()
+		case event := <-events:
+			if r.watched(event.Name) {
+				debounce = time.After(500 * time.Millisecond)
+			}
+		case err := <-failures:
+			log.Warn().
+				Err(err).
+				Msg("failed to watch config files")
+		case <-debounce:
+			debounce = nil
+
+			log.Info().
+				Msg("config files changed, reloading")
+
+			r.Reload()
+		}
+	}
+}
+
+// Reload rebuilds the configuration and applies it, on any error the
+// current configuration stays active.
+func (r *reloader) Reload() {
+	next, err := reloadConfig(r.ctx, r.cfg)
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("failed to reload config, keeping current config")
+
+		return
+	}
+
+	if problems := config.Validate(next); len(problems) > 0 {
+		for _, problem := range problems {
+			log.Error().
+				Err(problem).
+				Msg("invalid config")
+		}
+
+		log.Error().
+			Int("problems", len(problems)).
+			Msg("failed to validate config, keeping current config")
+
+		return
+	}
+
+	for _, key := range config.Diff(r.cfg, next) {
+		if restart(key) {
+			log.Warn().
+				Str("key", key).
+				Msg("config change requires a restart, ignoring")
+		}
+	}
+
+	next.Server.Health = r.cfg.Server.Health
+	next.Server.Secure = r.cfg.Server.Secure
+	next.Server.Public = r.cfg.Server.Public
+	next.Server.Cert = r.cfg.Server.Cert
+	next.Server.Key = r.cfg.Server.Key
+	next.Server.AutoCert = r.cfg.Server.AutoCert
+	next.Server.StrictCurves = r.cfg.Server.StrictCurves
+	next.Server.StrictCiphers = r.cfg.Server.StrictCiphers
+	next.Server.Storage = r.cfg.Server.Storage
+	next.Server.Watch = r.cfg.Server.Watch
+	next.Server.TrustedProxies = r.cfg.Server.TrustedProxies
+	next.Session = r.cfg.Session
+	next.JWT = r.cfg.JWT
+	next.Audit = r.cfg.Audit
+
+	changed := config.Diff(r.cfg, next)
+
+	if len(changed) == 0 {
+		log.Info().
+			Msg("config is unchanged")
+
+		return
+	}
+
+	skip, err := policy.NewSkip(next.Proxy.SkipAuth)
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("failed to reload skip-auth rules, keeping current config")
+
+		return
+	}
+
+	if err := r.proxy.Update(next); err != nil {
+		log.Error().
+			Err(err).
+			Msg("failed to reload upstreams, keeping current config")
+
+		return
+	}
+
+	serverProviders(next)
+	logging(next)
+
+	r.handler.Store(router.Load(next, r.sessions, r.signer, skip, r.proxy))
+	r.status.Store(router.Status(next, r.storage, r.proxy))
+	r.cfg = next
+
+	log.Info().
+		Strs("changed", changed).
+		Msg("reloaded config")
+}
+
+func (r *reloader) files() []string {
+	result := []string{}
+
+	for _, file := range []string{r.cfg.File, r.cfg.Proxy.RoutesFile} {
+		if file == "" {
+			continue
+		}
+
+		if abs, err := filepath.Abs(file); err == nil {
+			result = append(result, abs)
+		}
+	}
+
+	return result
+}
+
+func (r *reloader) dirs() []string {
+	result := []string{}
+
+	for _, file := range r.files() {
+		dir := filepath.Dir(file)
+
+		if !contains(result, dir) {
+			result = append(result, dir)
+		}
+	}
+
+	return result
+}
+
+func (r *reloader) watched(name string) bool {
+	abs, err := filepath.Abs(name)
+
+	if err != nil {
+		return false
+	}
+
+	return contains(r.files(), abs)
+}
+
+// reloadConfig builds a fresh configuration from the flag defaults, the
+// values explicitly set via flags or environment and the config file.
+func reloadConfig(c *cli.Context, cfg *config.Config) (*config.Config, error) {
+	next := config.New()
+
+	current := append(flags(cfg), serverFlags(cfg)...)
+	list := append(flags(next), serverFlags(next)...)
+
+	for i, f := range list {
+		switch f := f.(type) {
+		case *cli.StringFlag:
+			*f.Destination = f.Value
+
+			if explicit(c, f.Names(), f.EnvVars) {
+				*f.Destination = *current[i].(*cli.StringFlag).Destination
+			}
+		case *cli.BoolFlag:
+			*f.Destination = f.Value
+
+			if explicit(c, f.Names(), f.EnvVars) {
+				*f.Destination = *current[i].(*cli.BoolFlag).Destination
+			}
+		case *cli.IntFlag:
+			*f.Destination = f.Value
+
+			if explicit(c, f.Names(), f.EnvVars) {
+				*f.Destination = *current[i].(*cli.IntFlag).Destination
+			}
+		case *cli.DurationFlag:
+			*f.Destination = f.Value
+
+			if explicit(c, f.Names(), f.EnvVars) {
+				*f.Destination = *current[i].(*cli.DurationFlag).Destination
+			}
+		}
+	}
+
+	if err := configFile(c, next, list); err != nil {
+		return nil, err
+	}
+
+	if err := serverConfig(c, next); err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+func restart(key string) bool {
+	for _, prefix := range restartKeys {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+
+	return false
+}
@@ -11,19 +11,22 @@ import (
 	"path"
 	"time"
 
-	"github.com/go-chi/chi"
-	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
 	"github.com/markbates/goth/providers/bitbucket"
 	"github.com/markbates/goth/providers/github"
 	"github.com/markbates/goth/providers/gitlab"
 	"github.com/oklog/run"
 	"github.com/rs/zerolog/log"
-	"github.com/vulcand/oxy/buffer"
-	"github.com/vulcand/oxy/forward"
-	"github.com/vulcand/oxy/roundrobin"
+	"github.com/webhippie/oauth2-proxy/pkg/audit"
 	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"github.com/webhippie/oauth2-proxy/pkg/metrics"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
 	"github.com/webhippie/oauth2-proxy/pkg/router"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+	"github.com/webhippie/oauth2-proxy/pkg/upstream"
 	"golang.org/x/crypto/acme/autocert"
 	"gopkg.in/urfave/cli.v2"
 )
@@ -138,6 +141,131 @@ func serverFlags(cfg *config.Config) []cli.Flag {
 			EnvVars:     []string{"OAUTH2_PROXY_SERVER_STORAGE"},
 			Destination: &cfg.Server.Storage,
 		},
+		&cli.BoolFlag{
+			Name:        "config-watch",
+			Value:       false,
+			Usage:       "reload when config or routes file changes",
+			EnvVars:     []string{"OAUTH2_PROXY_CONFIG_WATCH"},
+			Destination: &cfg.Server.Watch,
+		},
+		&cli.StringSliceFlag{
+			Name:    "trusted-proxy",
+			Value:   &cli.StringSlice{},
+			Usage:   "address or cidr of proxies allowed to set forwarding headers",
+			EnvVars: []string{"OAUTH2_PROXY_SERVER_TRUSTED_PROXIES"},
+		},
+		&cli.StringFlag{
+			Name:        "session-secret",
+			Value:       "",
+			Usage:       "secret to encrypt and sign sessions",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_SECRET"},
+			Destination: &cfg.Session.Secret,
+		},
+		&cli.DurationFlag{
+			Name:        "session-lifetime",
+			Value:       24 * time.Hour,
+			Usage:       "lifetime of the sessions",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_LIFETIME"},
+			Destination: &cfg.Session.Lifetime,
+		},
+		&cli.StringFlag{
+			Name:        "session-cookie",
+			Value:       "_oauth2_proxy",
+			Usage:       "name of the session cookie",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_COOKIE"},
+			Destination: &cfg.Session.Name,
+		},
+		&cli.StringFlag{
+			Name:        "session-domain",
+			Value:       "",
+			Usage:       "domain of the session cookie",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_DOMAIN"},
+			Destination: &cfg.Session.Domain,
+		},
+		&cli.StringFlag{
+			Name:        "session-path",
+			Value:       "/",
+			Usage:       "path of the session cookie",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_PATH"},
+			Destination: &cfg.Session.Path,
+		},
+		&cli.BoolFlag{
+			Name:        "session-secure",
+			Value:       false,
+			Usage:       "send session cookie only via https",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_SECURE"},
+			Destination: &cfg.Session.Secure,
+		},
+		&cli.BoolFlag{
+			Name:        "session-httponly",
+			Value:       true,
+			Usage:       "hide session cookie from javascript",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_HTTPONLY"},
+			Destination: &cfg.Session.HTTPOnly,
+		},
+		&cli.StringFlag{
+			Name:        "session-samesite",
+			Value:       "lax",
+			Usage:       "samesite mode of the session cookie",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_SAMESITE"},
+			Destination: &cfg.Session.SameSite,
+		},
+		&cli.StringFlag{
+			Name:        "session-store",
+			Value:       "cookie",
+			Usage:       "session store, cookie, memory, file or redis",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_STORE"},
+			Destination: &cfg.Session.Store,
+		},
+		&cli.DurationFlag{
+			Name:        "session-sweep",
+			Value:       5 * time.Minute,
+			Usage:       "interval to sweep expired sessions",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_SWEEP"},
+			Destination: &cfg.Session.Sweep,
+		},
+		&cli.DurationFlag{
+			Name:        "session-refresh",
+			Value:       5 * time.Minute,
+			Usage:       "refresh access tokens expiring within this duration, 0 disables",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_REFRESH"},
+			Destination: &cfg.Session.Refresh,
+		},
+		&cli.DurationFlag{
+			Name:        "session-revalidate",
+			Value:       15 * time.Minute,
+			Usage:       "interval to check the organization membership again, 0 disables",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_REVALIDATE"},
+			Destination: &cfg.Session.Revalidate,
+		},
+		&cli.IntFlag{
+			Name:        "session-memory-size",
+			Value:       10000,
+			Usage:       "maximum sessions within memory store",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_MEMORY_SIZE"},
+			Destination: &cfg.Session.MemorySize,
+		},
+		&cli.StringFlag{
+			Name:        "session-redis-addr",
+			Value:       "127.0.0.1:6379",
+			Usage:       "address of the redis server",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_REDIS_ADDR"},
+			Destination: &cfg.Session.RedisAddr,
+		},
+		&cli.StringFlag{
+			Name:        "session-redis-password",
+			Value:       "",
+			Usage:       "password for the redis server",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_REDIS_PASSWORD"},
+			Destination: &cfg.Session.RedisPassword,
+		},
+		&cli.IntFlag{
+			Name:        "session-redis-db",
+			Value:       0,
+			Usage:       "database of the redis server",
+			EnvVars:     []string{"OAUTH2_PROXY_SESSION_REDIS_DB"},
+			Destination: &cfg.Session.RedisDB,
+		},
 		&cli.StringFlag{
 			Name:        "proxy-title",
 			Value:       "OAuth2 Proxy",
@@ -151,6 +279,78 @@ func serverFlags(cfg *config.Config) []cli.Flag {
 			Usage:   "endpoints to proxy requests to",
 			EnvVars: []string{"OAUTH2_PROXY_SERVER_ENDPOINTS"},
 		},
+		&cli.StringFlag{
+			Name:        "proxy-routes",
+			Value:       "",
+			Usage:       "path to yaml, toml or json file with routes",
+			EnvVars:     []string{"OAUTH2_PROXY_SERVER_ROUTES"},
+			Destination: &cfg.Proxy.RoutesFile,
+		},
+		&cli.StringFlag{
+			Name:        "proxy-check-path",
+			Value:       "",
+			Usage:       "path for active health checks of endpoints",
+			EnvVars:     []string{"OAUTH2_PROXY_CHECK_PATH"},
+			Destination: &cfg.Proxy.Check.Path,
+		},
+		&cli.DurationFlag{
+			Name:        "proxy-check-interval",
+			Value:       10 * time.Second,
+			Usage:       "interval between health checks",
+			EnvVars:     []string{"OAUTH2_PROXY_CHECK_INTERVAL"},
+			Destination: &cfg.Proxy.Check.Interval,
+		},
+		&cli.DurationFlag{
+			Name:        "proxy-check-timeout",
+			Value:       2 * time.Second,
+			Usage:       "timeout of a single health check",
+			EnvVars:     []string{"OAUTH2_PROXY_CHECK_TIMEOUT"},
+			Destination: &cfg.Proxy.Check.Timeout,
+		},
+		&cli.IntFlag{
+			Name:        "proxy-check-healthy",
+			Value:       2,
+			Usage:       "successful checks to mark an endpoint healthy",
+			EnvVars:     []string{"OAUTH2_PROXY_CHECK_HEALTHY"},
+			Destination: &cfg.Proxy.Check.Healthy,
+		},
+		&cli.IntFlag{
+			Name:        "proxy-check-unhealthy",
+			Value:       3,
+			Usage:       "failed checks to mark an endpoint unhealthy",
+			EnvVars:     []string{"OAUTH2_PROXY_CHECK_UNHEALTHY"},
+			Destination: &cfg.Proxy.Check.Unhealthy,
+		},
+		&cli.StringSliceFlag{
+			Name:    "proxy-skip-auth",
+			Value:   cli.NewStringSlice(),
+			Usage:   "requests proxied without auth, as [METHOD ]PATTERN[ CIDR]",
+			EnvVars: []string{"OAUTH2_PROXY_SKIP_AUTH"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "proxy-allow-user",
+			Value:   cli.NewStringSlice(),
+			Usage:   "usernames allowed to access the default route, scoped like github:jdoe",
+			EnvVars: []string{"OAUTH2_PROXY_ALLOW_USERS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "proxy-allow-email",
+			Value:   cli.NewStringSlice(),
+			Usage:   "verified emails allowed to access the default route",
+			EnvVars: []string{"OAUTH2_PROXY_ALLOW_EMAILS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "proxy-allow-domain",
+			Value:   cli.NewStringSlice(),
+			Usage:   "verified email domains allowed to access the default route",
+			EnvVars: []string{"OAUTH2_PROXY_ALLOW_DOMAINS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "proxy-redirect-host",
+			Value:   cli.NewStringSlice(),
+			Usage:   "additional hosts allowed as redirect after login, supports *.domain",
+			EnvVars: []string{"OAUTH2_PROXY_REDIRECT_HOSTS"},
+		},
 		&cli.StringFlag{
 			Name:        "user-header",
 			Value:       "X-PROXY-USER",
@@ -158,6 +358,132 @@ func serverFlags(cfg *config.Config) []cli.Flag {
 			EnvVars:     []string{"OAUTH2_PROXY_USER_HEADER"},
 			Destination: &cfg.Proxy.UserHeader,
 		},
+		&cli.StringFlag{
+			Name:        "email-header",
+			Value:       "X-PROXY-EMAIL",
+			Usage:       "header for email",
+			EnvVars:     []string{"OAUTH2_PROXY_EMAIL_HEADER"},
+			Destination: &cfg.Proxy.EmailHeader,
+		},
+		&cli.StringFlag{
+			Name:        "name-header",
+			Value:       "X-PROXY-NAME",
+			Usage:       "header for display name",
+			EnvVars:     []string{"OAUTH2_PROXY_NAME_HEADER"},
+			Destination: &cfg.Proxy.NameHeader,
+		},
+		&cli.StringFlag{
+			Name:        "groups-header",
+			Value:       "X-PROXY-GROUPS",
+			Usage:       "header for groups and orgs",
+			EnvVars:     []string{"OAUTH2_PROXY_GROUPS_HEADER"},
+			Destination: &cfg.Proxy.GroupsHeader,
+		},
+		&cli.StringFlag{
+			Name:        "provider-header",
+			Value:       "X-PROXY-PROVIDER",
+			Usage:       "header for provider name",
+			EnvVars:     []string{"OAUTH2_PROXY_PROVIDER_HEADER"},
+			Destination: &cfg.Proxy.ProviderHeader,
+		},
+		&cli.StringFlag{
+			Name:        "token-header",
+			Value:       "X-PROXY-TOKEN",
+			Usage:       "header for access token",
+			EnvVars:     []string{"OAUTH2_PROXY_TOKEN_HEADER"},
+			Destination: &cfg.Proxy.TokenHeader,
+		},
+		&cli.BoolFlag{
+			Name:        "pass-token",
+			Value:       false,
+			Usage:       "pass access token to upstream",
+			EnvVars:     []string{"OAUTH2_PROXY_PASS_TOKEN"},
+			Destination: &cfg.Proxy.PassToken,
+		},
+		&cli.BoolFlag{
+			Name:        "bearer-enabled",
+			Value:       false,
+			Usage:       "accept bearer tokens from providers",
+			EnvVars:     []string{"OAUTH2_PROXY_BEARER_ENABLED"},
+			Destination: &cfg.Proxy.Bearer,
+		},
+		&cli.DurationFlag{
+			Name:        "bearer-cache",
+			Value:       5 * time.Minute,
+			Usage:       "duration to cache validated bearer tokens",
+			EnvVars:     []string{"OAUTH2_PROXY_BEARER_CACHE"},
+			Destination: &cfg.Proxy.BearerCache,
+		},
+		&cli.StringFlag{
+			Name:        "bearer-provider",
+			Value:       "",
+			Usage:       "provider validating opaque bearer tokens, required with multiple providers",
+			EnvVars:     []string{"OAUTH2_PROXY_BEARER_PROVIDER"},
+			Destination: &cfg.Proxy.BearerProvider,
+		},
+		&cli.BoolFlag{
+			Name:        "jwt-enabled",
+			Value:       false,
+			Usage:       "pass signed assertion to upstream",
+			EnvVars:     []string{"OAUTH2_PROXY_JWT_ENABLED"},
+			Destination: &cfg.JWT.Enabled,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-header",
+			Value:       "X-PROXY-JWT",
+			Usage:       "header for signed assertion",
+			EnvVars:     []string{"OAUTH2_PROXY_JWT_HEADER"},
+			Destination: &cfg.JWT.Header,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-algorithm",
+			Value:       "RS256",
+			Usage:       "algorithm for signed assertion, RS256, ES256 or HS256",
+			EnvVars:     []string{"OAUTH2_PROXY_JWT_ALGORITHM"},
+			Destination: &cfg.JWT.Algorithm,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-key",
+			Value:       "signing.pem",
+			Usage:       "signing key file within storage jwt folder",
+			EnvVars:     []string{"OAUTH2_PROXY_JWT_KEY"},
+			Destination: &cfg.JWT.Key,
+		},
+		&cli.DurationFlag{
+			Name:        "jwt-lifetime",
+			Value:       time.Minute,
+			Usage:       "lifetime of signed assertion",
+			EnvVars:     []string{"OAUTH2_PROXY_JWT_LIFETIME"},
+			Destination: &cfg.JWT.Lifetime,
+		},
+		&cli.BoolFlag{
+			Name:        "logout-revoke",
+			Value:       false,
+			Usage:       "revoke the provider token on logout",
+			EnvVars:     []string{"OAUTH2_PROXY_LOGOUT_REVOKE"},
+			Destination: &cfg.Logout.Revoke,
+		},
+		&cli.StringFlag{
+			Name:        "logout-redirect",
+			Value:       "",
+			Usage:       "redirect after logout at the provider, defaults to the logout page",
+			EnvVars:     []string{"OAUTH2_PROXY_LOGOUT_REDIRECT"},
+			Destination: &cfg.Logout.Redirect,
+		},
+		&cli.BoolFlag{
+			Name:        "audit-enabled",
+			Value:       false,
+			Usage:       "enable the audit trail",
+			EnvVars:     []string{"OAUTH2_PROXY_AUDIT_ENABLED"},
+			Destination: &cfg.Audit.Enabled,
+		},
+		&cli.StringFlag{
+			Name:        "audit-output",
+			Value:       "stdout",
+			Usage:       "audit trail output, stdout, stderr or a file path",
+			EnvVars:     []string{"OAUTH2_PROXY_AUDIT_OUTPUT"},
+			Destination: &cfg.Audit.Output,
+		},
 		&cli.BoolFlag{
 			Name:        "oauth2-gitlab",
 			Value:       false,
@@ -253,128 +579,349 @@ func serverFlags(cfg *config.Config) []cli.Flag {
 			EnvVars:     []string{"OAUTH2_PROXY_BITBUCKET_SECRET"},
 			Destination: &cfg.Bitbucket.Secret,
 		},
+		&cli.BoolFlag{
+			Name:        "oauth2-oidc",
+			Value:       false,
+			Usage:       "enable openid connect provider",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC"},
+			Destination: &cfg.OIDC.Enabled,
+		},
+		&cli.StringSliceFlag{
+			Name:    "oauth2-oidc-org",
+			Value:   &cli.StringSlice{},
+			Usage:   "allowed groups from openid connect",
+			EnvVars: []string{"OAUTH2_PROXY_OIDC_ORGS"},
+		},
+		&cli.StringFlag{
+			Name:        "oauth2-oidc-client",
+			Value:       "",
+			Usage:       "openid connect client id",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC_CLIENT"},
+			Destination: &cfg.OIDC.Client,
+		},
+		&cli.StringFlag{
+			Name:        "oauth2-oidc-secret",
+			Value:       "",
+			Usage:       "openid connect client secret",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC_SECRET"},
+			Destination: &cfg.OIDC.Secret,
+		},
+		&cli.StringFlag{
+			Name:        "oauth2-oidc-issuer",
+			Value:       "",
+			Usage:       "openid connect issuer url",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC_ISSUER"},
+			Destination: &cfg.OIDC.Issuer,
+		},
+		&cli.StringFlag{
+			Name:        "oauth2-oidc-label",
+			Value:       "OpenID Connect",
+			Usage:       "label of openid connect on the login page",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC_LABEL"},
+			Destination: &cfg.OIDC.Label,
+		},
+		&cli.StringSliceFlag{
+			Name:    "oauth2-oidc-scope",
+			Value:   cli.NewStringSlice("openid", "profile", "email"),
+			Usage:   "requested scopes from openid connect",
+			EnvVars: []string{"OAUTH2_PROXY_OIDC_SCOPES"},
+		},
+		&cli.StringFlag{
+			Name:        "oauth2-oidc-username-claim",
+			Value:       "preferred_username",
+			Usage:       "claim used as username",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC_USERNAME_CLAIM"},
+			Destination: &cfg.OIDC.UsernameClaim,
+		},
+		&cli.StringFlag{
+			Name:        "oauth2-oidc-groups-claim",
+			Value:       "groups",
+			Usage:       "claim used for groups",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC_GROUPS_CLAIM"},
+			Destination: &cfg.OIDC.GroupsClaim,
+		},
+		&cli.BoolFlag{
+			Name:        "oauth2-oidc-skipverify",
+			Value:       false,
+			Usage:       "skip ssl verify for openid connect",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC_SKIPVERIFY"},
+			Destination: &cfg.OIDC.SkipVerify,
+		},
+		&cli.BoolFlag{
+			Name:        "oauth2-oidc-pkce",
+			Value:       true,
+			Usage:       "use pkce if supported by the issuer",
+			EnvVars:     []string{"OAUTH2_PROXY_OIDC_PKCE"},
+			Destination: &cfg.OIDC.PKCE,
+		},
 	}
 }
 
 func serverBefore(cfg *config.Config) cli.BeforeFunc {
 	return func(c *cli.Context) error {
-		if len(c.StringSlice("proxy-endpoint")) > 0 {
-			// StringSliceFlag doesn't support Destination
-			cfg.Proxy.Endpoints = c.StringSlice("proxy-endpoint")
+		if err := configFile(c, cfg, append(flags(cfg), serverFlags(cfg)...)); err != nil {
+			return err
 		}
 
-		gothic.GetProviderName = func(r *http.Request) (string, error) {
-			if provider := chi.URLParam(r, "provider"); provider != "" {
-				return provider, nil
+		if err := serverConfig(c, cfg); err != nil {
+			return err
+		}
+
+		serverProviders(cfg)
+		return nil
+	}
+}
+
+// serverConfig applies the list flags and loads the referenced routes file.
+func serverConfig(c *cli.Context, cfg *config.Config) error {
+	if len(c.StringSlice("proxy-endpoint")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.Proxy.Endpoints = c.StringSlice("proxy-endpoint")
+	}
+
+	if len(c.StringSlice("proxy-skip-auth")) > 0 {
+		rules := make([]config.SkipAuth, 0, len(c.StringSlice("proxy-skip-auth")))
+
+		for _, val := range c.StringSlice("proxy-skip-auth") {
+			rule, err := policy.ParseSkip(val)
+
+			if err != nil {
+				log.Error().
+					Err(err).
+					Msg("failed to parse skip-auth rule")
+
+				return err
 			}
 
-			return "", fmt.Errorf("you must select a provider")
+			rules = append(rules, rule)
 		}
 
-		if len(c.StringSlice("oauth2-gitlab-org")) > 0 {
-			// StringSliceFlag doesn't support Destination
-			cfg.Gitlab.Orgs = c.StringSlice("oauth2-gitlab-org")
-		}
+		cfg.Proxy.SkipAuth = rules
+	}
 
-		if cfg.Gitlab.Enabled {
-			goth.UseProviders(
-				gitlab.NewCustomisedURL(
-					cfg.Gitlab.Client,
-					cfg.Gitlab.Secret,
-					fmt.Sprintf("%s%s/gitlab", cfg.Server.Host, cfg.Server.Root),
-					fmt.Sprintf("%s/oauth/authorize", cfg.Gitlab.URL),
-					fmt.Sprintf("%s/oauth/token", cfg.Gitlab.URL),
-					fmt.Sprintf("%s/api/v3/user", cfg.Gitlab.URL),
-				),
-			)
-		}
+	if len(c.StringSlice("proxy-allow-user")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.Proxy.Policy.Allow.Users = c.StringSlice("proxy-allow-user")
+	}
 
-		if len(c.StringSlice("oauth2-github-org")) > 0 {
-			// StringSliceFlag doesn't support Destination
-			cfg.GitHub.Orgs = c.StringSlice("oauth2-github-org")
-		}
+	if len(c.StringSlice("proxy-allow-email")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.Proxy.Policy.Allow.Emails = c.StringSlice("proxy-allow-email")
+	}
 
-		if cfg.GitHub.Enabled {
-			goth.UseProviders(
-				github.New(
-					cfg.GitHub.Client,
-					cfg.GitHub.Secret,
-					fmt.Sprintf("%s%s/github", cfg.Server.Host, cfg.Server.Root),
-				),
-			)
+	if len(c.StringSlice("proxy-allow-domain")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.Proxy.Policy.Allow.Domains = c.StringSlice("proxy-allow-domain")
+	}
+
+	if len(c.StringSlice("trusted-proxy")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.Server.TrustedProxies = c.StringSlice("trusted-proxy")
+	}
+
+	if len(c.StringSlice("proxy-redirect-host")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.Proxy.RedirectHosts = c.StringSlice("proxy-redirect-host")
+	}
+
+	if cfg.Proxy.RoutesFile != "" {
+		routes, err := config.LoadRoutes(cfg.Proxy.RoutesFile)
+
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("file", cfg.Proxy.RoutesFile).
+				Msg("failed to load routes")
+
+			return err
 		}
 
-		if len(c.StringSlice("oauth2-bitbucket-org")) > 0 {
-			// StringSliceFlag doesn't support Destination
-			cfg.Bitbucket.Orgs = c.StringSlice("oauth2-bitbucket-org")
+		cfg.Proxy.Routes = routes
+	}
+
+	if len(c.StringSlice("oauth2-gitlab-org")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.Gitlab.Orgs = c.StringSlice("oauth2-gitlab-org")
+	}
+
+	if len(c.StringSlice("oauth2-github-org")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.GitHub.Orgs = c.StringSlice("oauth2-github-org")
+	}
+
+	if len(c.StringSlice("oauth2-bitbucket-org")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.Bitbucket.Orgs = c.StringSlice("oauth2-bitbucket-org")
+	}
+
+	if len(c.StringSlice("oauth2-oidc-org")) > 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.OIDC.Orgs = c.StringSlice("oauth2-oidc-org")
+	}
+
+	if c.IsSet("oauth2-oidc-scope") || len(cfg.OIDC.Scopes) == 0 {
+		// StringSliceFlag doesn't support Destination
+		cfg.OIDC.Scopes = c.StringSlice("oauth2-oidc-scope")
+	}
+
+	return nil
+}
+
+// serverProviders registers the enabled providers, the previously registered
+// providers get replaced at once.
+func serverProviders(cfg *config.Config) {
+	set := provider.NewSet()
+
+	if cfg.Gitlab.Enabled {
+		scopes := []string{"read_user"}
+
+		if len(cfg.Gitlab.Orgs) > 0 || policy.Memberships(cfg, "gitlab") {
+			scopes = append(scopes, "read_api")
 		}
 
-		if cfg.Bitbucket.Enabled {
-			goth.UseProviders(
-				bitbucket.New(
-					cfg.Bitbucket.Client,
-					cfg.Bitbucket.Secret,
-					fmt.Sprintf("%s%s/bitbucket", cfg.Server.Host, cfg.Server.Root),
-				),
-			)
+		set.Add(
+			provider.NewGitlab(cfg),
+			gitlab.NewCustomisedURL(
+				cfg.Gitlab.Client,
+				cfg.Gitlab.Secret,
+				fmt.Sprintf("%s%s/gitlab/callback", cfg.Server.Host, cfg.Server.Root),
+				fmt.Sprintf("%s/oauth/authorize", cfg.Gitlab.URL),
+				fmt.Sprintf("%s/oauth/token", cfg.Gitlab.URL),
+				fmt.Sprintf("%s/api/v4/user", cfg.Gitlab.URL),
+				scopes...,
+			),
+		)
+	}
+
+	if cfg.GitHub.Enabled {
+		scopes := []string{"read:user", "user:email"}
+
+		if len(cfg.GitHub.Orgs) > 0 || policy.Memberships(cfg, "github") {
+			scopes = append(scopes, "read:org")
 		}
 
-		return nil
+		set.Add(
+			provider.NewGitHub(cfg),
+			github.New(
+				cfg.GitHub.Client,
+				cfg.GitHub.Secret,
+				fmt.Sprintf("%s%s/github/callback", cfg.Server.Host, cfg.Server.Root),
+				scopes...,
+			),
+		)
+	}
+
+	if cfg.Bitbucket.Enabled {
+		set.Add(
+			provider.NewBitbucket(cfg),
+			bitbucket.New(
+				cfg.Bitbucket.Client,
+				cfg.Bitbucket.Secret,
+				fmt.Sprintf("%s%s/bitbucket/callback", cfg.Server.Host, cfg.Server.Root),
+			),
+		)
+	}
+
+	if cfg.OIDC.Enabled {
+		oidc := provider.NewOIDC(cfg)
+
+		set.Add(
+			oidc,
+			oidc,
+		)
 	}
+
+	provider.Replace(set)
 }
 
 func serverAction(cfg *config.Config) cli.ActionFunc {
 	return func(c *cli.Context) error {
-		fwd, err := forward.New(
-			forward.PassHostHeader(true),
-		)
+		proxy, err := upstream.New(cfg)
 
 		if err != nil {
 			log.Error().
 				Err(err).
-				Msg("failed to initialize forwarder")
+				Msg("failed to initialize upstreams")
 
 			return err
 		}
 
-		lb, err := roundrobin.New(fwd)
+		defer proxy.Close()
+
+		trail, err := audit.Setup(cfg)
 
 		if err != nil {
 			log.Error().
 				Err(err).
-				Msg("failed to initialize balancer")
+				Str("output", cfg.Audit.Output).
+				Msg("failed to initialize audit trail")
 
 			return err
 		}
 
-		proxy, err := buffer.New(
-			lb,
-			buffer.Retry(`IsNetworkError() && Attempts() < 3`),
-		)
+		defer trail.Close()
+
+		skip, err := policy.NewSkip(cfg.Proxy.SkipAuth)
 
 		if err != nil {
 			log.Error().
 				Err(err).
-				Msg("failed to initialize buffer")
+				Msg("failed to initialize skip-auth rules")
 
 			return err
 		}
 
-		for _, endpoint := range cfg.Proxy.Endpoints {
-			parsed, err := url.Parse(endpoint)
+		storage, err := store.New(cfg)
 
-			if err != nil {
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("store", cfg.Session.Store).
+				Msg("failed to initialize session store")
+
+			return err
+		}
+
+		defer storage.Close()
+
+		if counter, ok := storage.(store.Counter); ok {
+			if err := metrics.Sessions(counter.Count); err != nil {
 				log.Warn().
 					Err(err).
-					Str("endpoint", endpoint).
-					Msg("failed to parse endpoint")
+					Msg("failed to register session metrics")
+			}
+		}
+
+		sessions := session.New(cfg, storage)
+		gothic.Store = sessions.Store()
+
+		var signer *jwt.Signer
 
-				continue
+		if cfg.JWT.Enabled {
+			signer, err = jwt.LoadSigner(
+				path.Join(cfg.Server.Storage, "jwt"),
+				cfg.JWT.Key,
+				cfg.JWT.Algorithm,
+			)
+
+			if err != nil {
+				log.Error().
+					Err(err).
+					Str("key", cfg.JWT.Key).
+					Msg("failed to load signing key")
+
+				return err
 			}
+		}
 
-			lb.UpsertServer(parsed)
+		if len(proxy.Upstreams()) == 0 {
+			log.Info().
+				Msg("no upstream routes defined, only serving auth requests")
 		}
 
+		handler := router.NewReloadable(router.Load(cfg, sessions, signer, skip, proxy))
+		status := router.NewReloadable(router.Status(cfg, storage, proxy))
+
 		var gr run.Group
 
 		{
@@ -391,10 +938,31 @@ func serverAction(cfg *config.Config) cli.ActionFunc {
 			})
 		}
 
+		{
+			stop := make(chan struct{})
+
+			r := &reloader{
+				ctx:      c,
+				cfg:      cfg,
+				storage:  storage,
+				sessions: sessions,
+				signer:   signer,
+				proxy:    proxy,
+				handler:  handler,
+				status:   status,
+			}
+
+			gr.Add(func() error {
+				return r.Run(stop)
+			}, func(err error) {
+				close(stop)
+			})
+		}
+
 		{
 			server := &http.Server{
 				Addr:         cfg.Server.Health,
-				Handler:      router.Status(cfg),
+				Handler:      status,
 				ReadTimeout:  5 * time.Second,
 				WriteTimeout: 10 * time.Second,
 			}
@@ -477,7 +1045,7 @@ func serverAction(cfg *config.Config) cli.ActionFunc {
 			{
 				server := &http.Server{
 					Addr:         httpsAddr,
-					Handler:      router.Load(cfg, proxy),
+					Handler:      handler,
 					ReadTimeout:  5 * time.Second,
 					WriteTimeout: 10 * time.Second,
 					TLSConfig: &tls.Config{
@@ -563,7 +1131,7 @@ func serverAction(cfg *config.Config) cli.ActionFunc {
 			{
 				server := &http.Server{
 					Addr:         cfg.Server.Secure,
-					Handler:      router.Load(cfg, proxy),
+					Handler:      handler,
 					ReadTimeout:  5 * time.Second,
 					WriteTimeout: 10 * time.Second,
 					TLSConfig: &tls.Config{
@@ -605,7 +1173,7 @@ func serverAction(cfg *config.Config) cli.ActionFunc {
 		{
 			server := &http.Server{
 				Addr:         cfg.Server.Public,
-				Handler:      router.Load(cfg, proxy),
+				Handler:      handler,
 				ReadTimeout:  5 * time.Second,
 				WriteTimeout: 10 * time.Second,
 			}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+)
+
+const (
+	// Login marks the completion of a login flow.
+	Login = "login"
+
+	// Logout marks the termination of a session.
+	Logout = "logout"
+
+	// Access marks an access decision for a proxied request.
+	Access = "access"
+
+	// Refresh marks the refresh of a session.
+	Refresh = "refresh"
+
+	// Revalidate marks the membership check of an existing session.
+	Revalidate = "revalidate"
+
+	// Token marks the validation of a bearer token.
+	Token = "token"
+)
+
+const (
+	// Allowed marks a positive decision.
+	Allowed = "allowed"
+
+	// Denied marks a negative decision.
+	Denied = "denied"
+
+	// Failed marks an action which failed because of an error.
+	Failed = "failed"
+)
+
+var (
+	// logger stores the current audit logger, it gets swapped on setup.
+	logger atomic.Value
+)
+
+func init() {
+	logger.Store(zerolog.Nop())
+}
+
+// Event defines a single entry of the audit trail.
+type Event struct {
+	Action   string
+	Decision string
+	Reason   string
+	Provider string
+	Username string
+	Route    string
+}
+
+// Setup opens the configured sink for the audit trail, the returned closer
+// releases the sink.
+func Setup(cfg *config.Config) (io.Closer, error) {
+	if !cfg.Audit.Enabled {
+		logger.Store(zerolog.Nop())
+		return ioutil.NopCloser(nil), nil
+	}
+
+	var (
+		out    io.Writer
+		closer io.Closer = ioutil.NopCloser(nil)
+	)
+
+	switch cfg.Audit.Output {
+	case "", "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		file, err := os.OpenFile(cfg.Audit.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+
+		if err != nil {
+			return nil, err
+		}
+
+		out, closer = file, file
+	}
+
+	logger.Store(zerolog.New(out).With().Timestamp().Str("type", "audit").Logger())
+	return closer, nil
+}
+
+// Record writes the event together with the source IP and the request ID to
+// the audit trail.
+func Record(r *http.Request, e Event) {
+	l := logger.Load().(zerolog.Logger)
+
+	entry := l.Log().
+		Str("action", e.Action).
+		Str("decision", e.Decision).
+		Str("ip", remoteIP(r))
+
+	if id, ok := hlog.IDFromRequest(r); ok {
+		entry = entry.Str("request_id", id.String())
+	}
+
+	if e.Provider != "" {
+		entry = entry.Str("provider", e.Provider)
+	}
+
+	if e.Username != "" {
+		entry = entry.Str("username", e.Username)
+	}
+
+	if e.Route != "" {
+		entry = entry.Str("route", e.Route)
+	}
+
+	entry.
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Msg(e.Reason)
+}
+
+// remoteIP strips the port, the address has already been replaced by the
+// realip middleware if the request got forwarded by a trusted proxy.
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
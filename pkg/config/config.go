@@ -1,73 +1,215 @@
 package config
 
+import (
+	"strings"
+	"time"
+)
+
 // Server defines the server configuration.
 type Server struct {
-	Health        string
-	Secure        string
-	Public        string
-	Host          string
-	Root          string
-	Cert          string
-	Key           string
-	AutoCert      bool
-	StrictCurves  bool
-	StrictCiphers bool
-	Templates     string
-	Assets        string
-	Storage       string
+	Health         string   `json:"health" yaml:"health"`
+	Secure         string   `json:"secure" yaml:"secure"`
+	Public         string   `json:"public" yaml:"public"`
+	Host           string   `json:"host" yaml:"host"`
+	Root           string   `json:"root" yaml:"root"`
+	Cert           string   `json:"cert" yaml:"cert"`
+	Key            string   `json:"key" yaml:"key"`
+	AutoCert       bool     `json:"auto_cert" yaml:"auto_cert"`
+	StrictCurves   bool     `json:"strict_curves" yaml:"strict_curves"`
+	StrictCiphers  bool     `json:"strict_ciphers" yaml:"strict_ciphers"`
+	Templates      string   `json:"templates" yaml:"templates"`
+	Assets         string   `json:"assets" yaml:"assets"`
+	Storage        string   `json:"storage" yaml:"storage"`
+	Watch          bool     `json:"watch" yaml:"watch"`
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+}
+
+// Session defines the session configuration.
+type Session struct {
+	Secret        string        `json:"secret" yaml:"secret"`
+	Lifetime      time.Duration `json:"lifetime" yaml:"lifetime"`
+	Name          string        `json:"name" yaml:"name"`
+	Domain        string        `json:"domain" yaml:"domain"`
+	Path          string        `json:"path" yaml:"path"`
+	Secure        bool          `json:"secure" yaml:"secure"`
+	HTTPOnly      bool          `json:"http_only" yaml:"http_only"`
+	SameSite      string        `json:"same_site" yaml:"same_site"`
+	Store         string        `json:"store" yaml:"store"`
+	Sweep         time.Duration `json:"sweep" yaml:"sweep"`
+	Refresh       time.Duration `json:"refresh" yaml:"refresh"`
+	Revalidate    time.Duration `json:"revalidate" yaml:"revalidate"`
+	MemorySize    int           `json:"memory_size" yaml:"memory_size"`
+	RedisAddr     string        `json:"redis_addr" yaml:"redis_addr"`
+	RedisPassword string        `json:"redis_password" yaml:"redis_password"`
+	RedisDB       int           `json:"redis_db" yaml:"redis_db"`
+}
+
+// JWT defines the signed assertion configuration.
+type JWT struct {
+	Enabled   bool          `json:"enabled" yaml:"enabled"`
+	Header    string        `json:"header" yaml:"header"`
+	Algorithm string        `json:"algorithm" yaml:"algorithm"`
+	Key       string        `json:"key" yaml:"key"`
+	Lifetime  time.Duration `json:"lifetime" yaml:"lifetime"`
 }
 
 // Logs defines the logging configuration.
 type Logs struct {
-	Level   string
-	Colored bool
-	Pretty  bool
+	Level   string `json:"level" yaml:"level"`
+	Colored bool   `json:"colored" yaml:"colored"`
+	Pretty  bool   `json:"pretty" yaml:"pretty"`
+}
+
+// Logout defines the logout configuration.
+type Logout struct {
+	Revoke   bool   `json:"revoke" yaml:"revoke"`
+	Redirect string `json:"redirect" yaml:"redirect"`
+}
+
+// Audit defines the audit trail configuration.
+type Audit struct {
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	Output  string `json:"output" yaml:"output"`
+}
+
+// Rules defines the subjects matched by an access policy.
+type Rules struct {
+	Users      []string            `json:"users" yaml:"users"`
+	Emails     []string            `json:"emails" yaml:"emails"`
+	Domains    []string            `json:"domains" yaml:"domains"`
+	Orgs       []string            `json:"orgs" yaml:"orgs"`
+	Teams      []string            `json:"teams" yaml:"teams"`
+	Groups     []string            `json:"groups" yaml:"groups"`
+	Workspaces []string            `json:"workspaces" yaml:"workspaces"`
+	Claims     map[string][]string `json:"claims" yaml:"claims"`
+}
+
+// Policy defines the access rules of a route.
+type Policy struct {
+	Allow Rules `json:"allow" yaml:"allow"`
+	Deny  Rules `json:"deny" yaml:"deny"`
+}
+
+// Check defines the active health checks of upstream endpoints.
+type Check struct {
+	Path      string        `json:"path" yaml:"path"`
+	Interval  time.Duration `json:"interval" yaml:"interval"`
+	Timeout   time.Duration `json:"timeout" yaml:"timeout"`
+	Healthy   int           `json:"healthy" yaml:"healthy"`
+	Unhealthy int           `json:"unhealthy" yaml:"unhealthy"`
+}
+
+// Route defines a routing rule to a pool of upstream endpoints.
+type Route struct {
+	Name      string   `json:"name" yaml:"name"`
+	Host      string   `json:"host" yaml:"host"`
+	Path      string   `json:"path" yaml:"path"`
+	Strip     bool     `json:"strip" yaml:"strip"`
+	Endpoints []string `json:"endpoints" yaml:"endpoints"`
+	Policy    Policy   `json:"policy" yaml:"policy"`
+	Check     Check    `json:"check" yaml:"check"`
+}
+
+// SkipAuth defines a request pattern which bypasses the authentication.
+type SkipAuth struct {
+	Method string `json:"method" yaml:"method"`
+	Path   string `json:"path" yaml:"path"`
+	CIDR   string `json:"cidr" yaml:"cidr"`
 }
 
 // Proxy defines the proxy configuration.
 type Proxy struct {
-	Title      string
-	Endpoints  []string
-	UserHeader string
+	Title          string        `json:"title" yaml:"title"`
+	Endpoints      []string      `json:"endpoints" yaml:"endpoints"`
+	RoutesFile     string        `json:"routes_file" yaml:"routes_file"`
+	Routes         []Route       `json:"routes" yaml:"routes"`
+	Policy         Policy        `json:"policy" yaml:"policy"`
+	SkipAuth       []SkipAuth    `json:"skip_auth" yaml:"skip_auth"`
+	Check          Check         `json:"check" yaml:"check"`
+	RedirectHosts  []string      `json:"redirect_hosts" yaml:"redirect_hosts"`
+	UserHeader     string        `json:"user_header" yaml:"user_header"`
+	EmailHeader    string        `json:"email_header" yaml:"email_header"`
+	NameHeader     string        `json:"name_header" yaml:"name_header"`
+	GroupsHeader   string        `json:"groups_header" yaml:"groups_header"`
+	ProviderHeader string        `json:"provider_header" yaml:"provider_header"`
+	TokenHeader    string        `json:"token_header" yaml:"token_header"`
+	PassToken      bool          `json:"pass_token" yaml:"pass_token"`
+	Bearer         bool          `json:"bearer" yaml:"bearer"`
+	BearerCache    time.Duration `json:"bearer_cache" yaml:"bearer_cache"`
+	BearerProvider string        `json:"bearer_provider" yaml:"bearer_provider"`
 }
 
 // Gitlab defines the gitlab configuration.
 type Gitlab struct {
-	Enabled    bool
-	Orgs       []string
-	Client     string
-	Secret     string
-	URL        string
-	SkipVerify bool
+	Enabled    bool     `json:"enabled" yaml:"enabled"`
+	Orgs       []string `json:"orgs" yaml:"orgs"`
+	Client     string   `json:"client" yaml:"client"`
+	Secret     string   `json:"secret" yaml:"secret"`
+	URL        string   `json:"url" yaml:"url"`
+	SkipVerify bool     `json:"skip_verify" yaml:"skip_verify"`
 }
 
 // GitHub defines the github configuration.
 type GitHub struct {
-	Enabled bool
-	Orgs    []string
-	Client  string
-	Secret  string
+	Enabled bool     `json:"enabled" yaml:"enabled"`
+	Orgs    []string `json:"orgs" yaml:"orgs"`
+	Client  string   `json:"client" yaml:"client"`
+	Secret  string   `json:"secret" yaml:"secret"`
 }
 
 // Bitbucket defines the bitbucket configuration.
 type Bitbucket struct {
-	Enabled bool
-	Orgs    []string
-	Client  string
-	Secret  string
+	Enabled bool     `json:"enabled" yaml:"enabled"`
+	Orgs    []string `json:"orgs" yaml:"orgs"`
+	Client  string   `json:"client" yaml:"client"`
+	Secret  string   `json:"secret" yaml:"secret"`
+}
+
+// OIDC defines the openid connect configuration.
+type OIDC struct {
+	Enabled       bool     `json:"enabled" yaml:"enabled"`
+	Orgs          []string `json:"orgs" yaml:"orgs"`
+	Client        string   `json:"client" yaml:"client"`
+	Secret        string   `json:"secret" yaml:"secret"`
+	Issuer        string   `json:"issuer" yaml:"issuer"`
+	Label         string   `json:"label" yaml:"label"`
+	Scopes        []string `json:"scopes" yaml:"scopes"`
+	UsernameClaim string   `json:"username_claim" yaml:"username_claim"`
+	GroupsClaim   string   `json:"groups_claim" yaml:"groups_claim"`
+	PKCE          bool     `json:"pkce" yaml:"pkce"`
+	SkipVerify    bool     `json:"skip_verify" yaml:"skip_verify"`
 }
 
 // Config defines the general configuration.
 type Config struct {
-	Server    Server
-	Logs      Logs
-	Proxy     Proxy
-	Gitlab    Gitlab
-	GitHub    GitHub
-	Bitbucket Bitbucket
+	Server    Server    `json:"server" yaml:"server"`
+	Session   Session   `json:"session" yaml:"session"`
+	JWT       JWT       `json:"jwt" yaml:"jwt"`
+	Logs      Logs      `json:"logs" yaml:"logs"`
+	Logout    Logout    `json:"logout" yaml:"logout"`
+	Audit     Audit     `json:"audit" yaml:"audit"`
+	Proxy     Proxy     `json:"proxy" yaml:"proxy"`
+	Gitlab    Gitlab    `json:"gitlab" yaml:"gitlab"`
+	GitHub    GitHub    `json:"github" yaml:"github"`
+	Bitbucket Bitbucket `json:"bitbucket" yaml:"bitbucket"`
+	OIDC      OIDC      `json:"oidc" yaml:"oidc"`
+	File      string    `json:"-" yaml:"-"`
 }
 
 // New prepares a new default configuration.
 func New() *Config {
 	return &Config{}
 }
+
+// Scope splits a policy value like github:jdoe into the provider and the
+// value, the provider is empty if the value isn't scoped.
+func Scope(val string) (string, string) {
+	if i := strings.Index(val, ":"); i > 0 {
+		switch val[:i] {
+		case "gitlab", "github", "bitbucket", "oidc":
+			return val[:i], val[i+1:]
+		}
+	}
+
+	return "", val
+}
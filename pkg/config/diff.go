@@ -0,0 +1,36 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Diff returns the keys which differ between both configurations, lists
+// and maps are compared as a whole.
+func Diff(a, b *Config) []string {
+	return diff("", reflect.ValueOf(*a), reflect.ValueOf(*b))
+}
+
+func diff(path string, a, b reflect.Value) []string {
+	if a.Kind() != reflect.Struct {
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			return nil
+		}
+
+		return []string{key(path)}
+	}
+
+	result := []string{}
+
+	for i := 0; i < a.NumField(); i++ {
+		name := strings.Split(a.Type().Field(i).Tag.Get("yaml"), ",")[0]
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		result = append(result, diff(join(path, name), a.Field(i), b.Field(i))...)
+	}
+
+	return result
+}
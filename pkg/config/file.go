@@ -0,0 +1,237 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Load reads a YAML, TOML or JSON file on top of the given configuration,
+// keys missing within the file keep their current value.
+func Load(file string, cfg *Config) error {
+	return decode(file, cfg)
+}
+
+// decode parses the file based on its extension and assigns the content to
+// the value, unknown keys and invalid types are reported with their path.
+func decode(file string, val interface{}) error {
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	var raw interface{}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(content, &raw)
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		result := map[string]interface{}{}
+		_, err = toml.Decode(string(content), &result)
+		raw = result
+	default:
+		return fmt.Errorf("unsupported config format %s", filepath.Ext(file))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %s", file, err)
+	}
+
+	if raw == nil {
+		return nil
+	}
+
+	if err := assign("", raw, reflect.ValueOf(val).Elem()); err != nil {
+		return fmt.Errorf("invalid %s: %s", file, err)
+	}
+
+	return nil
+}
+
+func assign(path string, raw interface{}, dst reflect.Value) error {
+	if dst.Type() == durationType {
+		switch val := raw.(type) {
+		case string:
+			parsed, err := time.ParseDuration(val)
+
+			if err != nil {
+				return fmt.Errorf("%s: invalid duration %q", key(path), val)
+			}
+
+			dst.SetInt(int64(parsed))
+			return nil
+		default:
+			return fmt.Errorf("%s: expected duration like \"5m\", got %v", key(path), raw)
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		val, ok := raw.(string)
+
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %v", key(path), raw)
+		}
+
+		dst.SetString(val)
+	case reflect.Bool:
+		val, ok := raw.(bool)
+
+		if !ok {
+			return fmt.Errorf("%s: expected boolean, got %v", key(path), raw)
+		}
+
+		dst.SetBool(val)
+	case reflect.Int, reflect.Int64:
+		switch val := raw.(type) {
+		case int:
+			dst.SetInt(int64(val))
+		case int64:
+			dst.SetInt(val)
+		case float64:
+			if val != float64(int64(val)) {
+				return fmt.Errorf("%s: expected integer, got %v", key(path), raw)
+			}
+
+			dst.SetInt(int64(val))
+		default:
+			return fmt.Errorf("%s: expected integer, got %v", key(path), raw)
+		}
+	case reflect.Slice:
+		list, ok := raw.([]interface{})
+
+		if !ok {
+			if maps, ok := raw.([]map[string]interface{}); ok {
+				for _, item := range maps {
+					list = append(list, item)
+				}
+			} else {
+				return fmt.Errorf("%s: expected list, got %v", key(path), raw)
+			}
+		}
+
+		result := reflect.MakeSlice(dst.Type(), len(list), len(list))
+
+		for i, item := range list {
+			if err := assign(fmt.Sprintf("%s[%d]", path, i), item, result.Index(i)); err != nil {
+				return err
+			}
+		}
+
+		dst.Set(result)
+	case reflect.Map:
+		values, err := mapping(path, raw)
+
+		if err != nil {
+			return err
+		}
+
+		result := reflect.MakeMapWithSize(dst.Type(), len(values))
+
+		for name, item := range values {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+
+			if err := assign(join(path, name), item, elem); err != nil {
+				return err
+			}
+
+			result.SetMapIndex(reflect.ValueOf(name), elem)
+		}
+
+		dst.Set(result)
+	case reflect.Struct:
+		values, err := mapping(path, raw)
+
+		if err != nil {
+			return err
+		}
+
+		fields := map[string]reflect.Value{}
+
+		for i := 0; i < dst.NumField(); i++ {
+			name := strings.Split(dst.Type().Field(i).Tag.Get("yaml"), ",")[0]
+
+			if name != "" && name != "-" {
+				fields[name] = dst.Field(i)
+			}
+		}
+
+		names := make([]string, 0, len(values))
+
+		for name := range values {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			field, ok := fields[name]
+
+			if !ok {
+				return fmt.Errorf("%s: unknown key", key(join(path, name)))
+			}
+
+			if err := assign(join(path, name), values[name], field); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported type %s", key(path), dst.Type())
+	}
+
+	return nil
+}
+
+func mapping(path string, raw interface{}) (map[string]interface{}, error) {
+	switch val := raw.(type) {
+	case map[string]interface{}:
+		return val, nil
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(val))
+
+		for name, item := range val {
+			str, ok := name.(string)
+
+			if !ok {
+				return nil, fmt.Errorf("%s: expected string keys, got %v", key(path), name)
+			}
+
+			result[str] = item
+		}
+
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%s: expected mapping, got %v", key(path), raw)
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+func key(path string) string {
+	if path == "" {
+		return "root"
+	}
+
+	return path
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	yamlConfig = `
+session:
+  lifetime: 12h
+  refresh: 90s
+  memory_size: 500
+  store: memory
+github:
+  enabled: true
+  orgs:
+  - acme
+oidc:
+  enabled: true
+  issuer: https://id.example.com
+  scopes: [openid, email]
+proxy:
+  policy:
+    allow:
+      domains: [example.com]
+  routes:
+  - name: admin
+    host: admin.example.com
+    path: /admin
+    strip: true
+    endpoints:
+    - http://10.0.0.1:8080
+    - http://10.0.0.2:8080
+    policy:
+      allow:
+        users: [github:alice]
+        claims:
+          department: [engineering]
+      deny:
+        orgs: [contractors]
+    check:
+      path: /healthz
+      interval: 5s
+`
+
+	tomlConfig = `
+[session]
+lifetime = "12h"
+refresh = "90s"
+memory_size = 500
+store = "memory"
+
+[github]
+enabled = true
+orgs = ["acme"]
+
+[oidc]
+enabled = true
+issuer = "https://id.example.com"
+scopes = ["openid", "email"]
+
+[proxy.policy.allow]
+domains = ["example.com"]
+
+[[proxy.routes]]
+name = "admin"
+host = "admin.example.com"
+path = "/admin"
+strip = true
+endpoints = ["http://10.0.0.1:8080", "http://10.0.0.2:8080"]
+
+[proxy.routes.policy.allow]
+users = ["github:alice"]
+
+[proxy.routes.policy.allow.claims]
+department = ["engineering"]
+
+[proxy.routes.policy.deny]
+orgs = ["contractors"]
+
+[proxy.routes.check]
+path = "/healthz"
+interval = "5s"
+`
+
+	jsonConfig = `{
+  "session": {"lifetime": "12h", "refresh": "90s", "memory_size": 500, "store": "memory"},
+  "github": {"enabled": true, "orgs": ["acme"]},
+  "oidc": {"enabled": true, "issuer": "https://id.example.com", "scopes": ["openid", "email"]},
+  "proxy": {
+    "policy": {"allow": {"domains": ["example.com"]}},
+    "routes": [{
+      "name": "admin",
+      "host": "admin.example.com",
+      "path": "/admin",
+      "strip": true,
+      "endpoints": ["http://10.0.0.1:8080", "http://10.0.0.2:8080"],
+      "policy": {
+        "allow": {"users": ["github:alice"], "claims": {"department": ["engineering"]}},
+        "deny": {"orgs": ["contractors"]}
+      },
+      "check": {"path": "/healthz", "interval": "5s"}
+    }]
+  }
+}`
+)
+
+// writeConfig writes the content into a temporary file with the given name.
+func writeConfig(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	return file
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+
+	if err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	route := Route{
+		Name:      "admin",
+		Host:      "admin.example.com",
+		Path:      "/admin",
+		Strip:     true,
+		Endpoints: []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"},
+		Policy: Policy{
+			Allow: Rules{
+				Users:  []string{"github:alice"},
+				Claims: map[string][]string{"department": {"engineering"}},
+			},
+			Deny: Rules{
+				Orgs: []string{"contractors"},
+			},
+		},
+		Check: Check{
+			Path:     "/healthz",
+			Interval: 5 * time.Second,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", yamlConfig},
+		{"config.yml", yamlConfig},
+		{"config.toml", tomlConfig},
+		{"config.json", jsonConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := New()
+			cfg.Server.Host = "http://keep.example.com"
+			cfg.Session.Name = "_keep"
+
+			if err := Load(writeConfig(t, dir, tt.name, tt.content), cfg); err != nil {
+				t.Fatalf("failed to load config: %s", err)
+			}
+
+			if cfg.Server.Host != "http://keep.example.com" || cfg.Session.Name != "_keep" {
+				t.Errorf("expected missing keys to keep their value, got %q and %q", cfg.Server.Host, cfg.Session.Name)
+			}
+
+			if cfg.Session.Lifetime != 12*time.Hour || cfg.Session.Refresh != 90*time.Second {
+				t.Errorf("expected durations 12h and 90s, got %s and %s", cfg.Session.Lifetime, cfg.Session.Refresh)
+			}
+
+			if cfg.Session.MemorySize != 500 || cfg.Session.Store != "memory" {
+				t.Errorf("expected memory store of 500, got %s of %d", cfg.Session.Store, cfg.Session.MemorySize)
+			}
+
+			if !cfg.GitHub.Enabled || !reflect.DeepEqual(cfg.GitHub.Orgs, []string{"acme"}) {
+				t.Errorf("expected github with acme, got %+v", cfg.GitHub)
+			}
+
+			if !cfg.OIDC.Enabled || cfg.OIDC.Issuer != "https://id.example.com" || !reflect.DeepEqual(cfg.OIDC.Scopes, []string{"openid", "email"}) {
+				t.Errorf("expected oidc provider, got %+v", cfg.OIDC)
+			}
+
+			if !reflect.DeepEqual(cfg.Proxy.Policy.Allow.Domains, []string{"example.com"}) {
+				t.Errorf("expected global policy, got %+v", cfg.Proxy.Policy)
+			}
+
+			if len(cfg.Proxy.Routes) != 1 || !reflect.DeepEqual(cfg.Proxy.Routes[0], route) {
+				t.Errorf("expected route %+v, got %+v", route, cfg.Proxy.Routes)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+
+	if err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"unknown.yaml", "sesion:\n  lifetime: 1h\n", "sesion: unknown key"},
+		{"nested.yaml", "proxy:\n  routes:\n  - name: admin\n    policy:\n      allow:\n        user: [alice]\n", "proxy.routes[0].policy.allow.user: unknown key"},
+		{"nested.toml", "[[proxy.routes]]\nname = \"admin\"\nendpoint = [\"http://x\"]\n", "proxy.routes[0].endpoint: unknown key"},
+		{"nested.json", `{"github": {"enabled": true, "org": ["acme"]}}`, "github.org: unknown key"},
+		{"duration.yaml", "session:\n  lifetime: 12 hours\n", `session.lifetime: invalid duration "12 hours"`},
+		{"number.json", `{"session": {"lifetime": 3600}}`, "session.lifetime: expected duration"},
+		{"integer.json", `{"session": {"memory_size": 1.5}}`, "session.memory_size: expected integer"},
+		{"string.yaml", "server:\n  host: [a, b]\n", "server.host: expected string"},
+		{"bool.toml", "[github]\nenabled = \"yes\"\n", "github.enabled: expected boolean"},
+		{"list.yaml", "github:\n  orgs: acme\n", "github.orgs: expected list"},
+		{"syntax.json", `{"session": `, "failed to parse"},
+		{"config.ini", "[session]\n", "unsupported config format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Load(writeConfig(t, dir, tt.name, tt.content), New())
+
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestLoadRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+
+	if err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	routes, err := LoadRoutes(writeConfig(t, dir, "routes.yml", "routes:\n- name: wiki\n  path: /wiki\n  endpoints: [http://10.0.0.3]\n"))
+
+	if err != nil {
+		t.Fatalf("failed to load routes: %s", err)
+	}
+
+	if len(routes) != 1 || routes[0].Name != "wiki" || routes[0].Endpoints[0] != "http://10.0.0.3" {
+		t.Errorf("expected wiki route, got %+v", routes)
+	}
+
+	if _, err := LoadRoutes(writeConfig(t, dir, "invalid.yml", "routes:\n- nme: wiki\n")); err == nil || !strings.Contains(err.Error(), "routes[0].nme: unknown key") {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
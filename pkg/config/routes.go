@@ -0,0 +1,14 @@
+package config
+
+// LoadRoutes parses a YAML, TOML or JSON file containing a list of routes.
+func LoadRoutes(file string) ([]Route, error) {
+	routes := struct {
+		Routes []Route `json:"routes" yaml:"routes"`
+	}{}
+
+	if err := decode(file, &routes); err != nil {
+		return nil, err
+	}
+
+	return routes.Routes, nil
+}
@@ -0,0 +1,444 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Validate checks all fields and cross-field constraints of the
+// configuration, it returns every problem found instead of the first one.
+func Validate(cfg *Config) []error {
+	v := &validator{}
+
+	v.server(cfg)
+	v.session(cfg)
+	v.jwt(cfg)
+	v.logs(cfg)
+	v.logout(cfg)
+	v.audit(cfg)
+	v.proxy(cfg)
+	v.providers(cfg)
+
+	return v.problems
+}
+
+type validator struct {
+	problems []error
+}
+
+func (v *validator) add(key, format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) url(key, val string) {
+	parsed, err := url.Parse(val)
+
+	switch {
+	case err != nil:
+		v.add(key, "invalid url %q: %s", val, err)
+	case parsed.Scheme != "http" && parsed.Scheme != "https":
+		v.add(key, "url %q requires http or https scheme", val)
+	case parsed.Host == "":
+		v.add(key, "url %q requires a host", val)
+	}
+}
+
+func (v *validator) addr(key, val string) {
+	if _, _, err := net.SplitHostPort(val); err != nil {
+		v.add(key, "invalid address %q: %s", val, err)
+	}
+}
+
+func (v *validator) file(key, val string) {
+	if _, err := os.Stat(val); err != nil {
+		v.add(key, "file %q is not accessible", val)
+	}
+}
+
+func (v *validator) server(cfg *Config) {
+	v.url("server.host", cfg.Server.Host)
+	v.addr("server.health", cfg.Server.Health)
+	v.addr("server.public", cfg.Server.Public)
+
+	if cfg.Server.Root != "" && (!strings.HasPrefix(cfg.Server.Root, "/") || strings.HasSuffix(cfg.Server.Root, "/")) {
+		v.add("server.root", "path %q must start and must not end with a slash", cfg.Server.Root)
+	}
+
+	if (cfg.Server.Cert == "") != (cfg.Server.Key == "") {
+		v.add("server.cert", "cert and key must be defined together")
+	}
+
+	if cfg.Server.Cert != "" && cfg.Server.Key != "" {
+		v.addr("server.secure", cfg.Server.Secure)
+		v.file("server.cert", cfg.Server.Cert)
+		v.file("server.key", cfg.Server.Key)
+
+		if cfg.Server.AutoCert {
+			v.add("server.auto_cert", "can't be combined with cert and key")
+		}
+	}
+
+	if cfg.Server.Templates != "" {
+		v.file("server.templates", cfg.Server.Templates)
+	}
+
+	if cfg.Server.Assets != "" {
+		v.file("server.assets", cfg.Server.Assets)
+	}
+
+	for i, val := range cfg.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(val); err != nil && net.ParseIP(val) == nil {
+			v.add(fmt.Sprintf("server.trusted_proxies[%d]", i), "invalid address or cidr %q", val)
+		}
+	}
+}
+
+func (v *validator) session(cfg *Config) {
+	if cfg.Session.Lifetime <= 0 {
+		v.add("session.lifetime", "must be greater than zero")
+	}
+
+	if cfg.Session.Refresh < 0 {
+		v.add("session.refresh", "must not be negative")
+	}
+
+	if cfg.Session.Revalidate < 0 {
+		v.add("session.revalidate", "must not be negative")
+	}
+
+	if cfg.Session.Name == "" {
+		v.add("session.name", "must not be empty")
+	}
+
+	switch strings.ToLower(cfg.Session.SameSite) {
+	case "", "lax", "strict":
+	case "none":
+		if !cfg.Session.Secure {
+			v.add("session.same_site", "none requires a secure session cookie")
+		}
+	default:
+		v.add("session.same_site", "unknown value %q, expected lax, strict or none", cfg.Session.SameSite)
+	}
+
+	switch cfg.Session.Store {
+	case "", "cookie":
+	case "memory":
+		if cfg.Session.MemorySize <= 0 {
+			v.add("session.memory_size", "must be greater than zero")
+		}
+
+		if cfg.Session.Sweep <= 0 {
+			v.add("session.sweep", "must be greater than zero")
+		}
+	case "file":
+		if cfg.Session.Sweep <= 0 {
+			v.add("session.sweep", "must be greater than zero")
+		}
+
+		if cfg.Session.Secret == "" {
+			v.add("session.secret", "required for file store, sessions would not survive a restart")
+		}
+	case "redis":
+		if cfg.Session.Secret == "" {
+			v.add("session.secret", "required for redis store, sessions would not survive a restart")
+		}
+
+		if cfg.Session.RedisAddr == "" {
+			v.add("session.redis_addr", "required for redis store")
+		} else {
+			v.addr("session.redis_addr", cfg.Session.RedisAddr)
+		}
+
+		if cfg.Session.RedisDB < 0 {
+			v.add("session.redis_db", "must not be negative")
+		}
+	default:
+		v.add("session.store", "unknown store %q, expected cookie, memory, file or redis", cfg.Session.Store)
+	}
+}
+
+func (v *validator) jwt(cfg *Config) {
+	if !cfg.JWT.Enabled {
+		return
+	}
+
+	if cfg.JWT.Header == "" {
+		v.add("jwt.header", "must not be empty")
+	}
+
+	switch cfg.JWT.Algorithm {
+	case "HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "ES256", "ES384", "ES512":
+	default:
+		v.add("jwt.algorithm", "unsupported algorithm %q", cfg.JWT.Algorithm)
+	}
+
+	if cfg.JWT.Key == "" {
+		v.add("jwt.key", "must not be empty")
+	}
+
+	if cfg.JWT.Lifetime <= 0 {
+		v.add("jwt.lifetime", "must be greater than zero")
+	}
+}
+
+func (v *validator) logs(cfg *Config) {
+	switch strings.ToLower(cfg.Logs.Level) {
+	case "", "debug", "info", "warn", "error", "fatal", "panic":
+	default:
+		v.add("logs.level", "unknown level %q", cfg.Logs.Level)
+	}
+}
+
+func (v *validator) logout(cfg *Config) {
+	if cfg.Logout.Redirect != "" {
+		v.url("logout.redirect", cfg.Logout.Redirect)
+	}
+}
+
+func (v *validator) audit(cfg *Config) {
+	if !cfg.Audit.Enabled {
+		return
+	}
+
+	switch cfg.Audit.Output {
+	case "":
+		v.add("audit.output", "must not be empty")
+	case "stdout", "stderr":
+	default:
+		v.file("audit.output", filepath.Dir(cfg.Audit.Output))
+	}
+}
+
+func (v *validator) proxy(cfg *Config) {
+	for i, endpoint := range cfg.Proxy.Endpoints {
+		v.url(fmt.Sprintf("proxy.endpoints[%d]", i), endpoint)
+	}
+
+	names := map[string]bool{}
+
+	for i, route := range cfg.Proxy.Routes {
+		key := fmt.Sprintf("proxy.routes[%d]", i)
+
+		if route.Name != "" {
+			if names[route.Name] {
+				v.add(key+".name", "duplicate route name %q", route.Name)
+			}
+
+			names[route.Name] = true
+		}
+
+		if route.Path != "" && !strings.HasPrefix(route.Path, "/") {
+			v.add(key+".path", "path %q must start with a slash", route.Path)
+		}
+
+		if strings.Contains(strings.TrimPrefix(route.Host, "*."), "*") {
+			v.add(key+".host", "wildcards are only supported as leading *.")
+		}
+
+		if len(route.Endpoints) == 0 {
+			v.add(key+".endpoints", "at least one endpoint is required")
+		}
+
+		for j, endpoint := range route.Endpoints {
+			v.url(fmt.Sprintf("%s.endpoints[%d]", key, j), endpoint)
+		}
+
+		v.policy(key+".policy", route.Policy, multiple(cfg))
+		v.check(key+".check", route.Check)
+	}
+
+	v.policy("proxy.policy", cfg.Proxy.Policy, multiple(cfg))
+	v.check("proxy.check", cfg.Proxy.Check)
+
+	for i, rule := range cfg.Proxy.SkipAuth {
+		key := fmt.Sprintf("proxy.skip_auth[%d]", i)
+
+		if rule.Path == "" {
+			v.add(key+".path", "must not be empty")
+		}
+
+		if rule.CIDR != "" {
+			if _, _, err := net.ParseCIDR(rule.CIDR); err != nil {
+				v.add(key+".cidr", "invalid cidr %q", rule.CIDR)
+			}
+		}
+	}
+
+	for i, host := range cfg.Proxy.RedirectHosts {
+		if host == "" || strings.Contains(host, "/") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			v.add(fmt.Sprintf("proxy.redirect_hosts[%d]", i), "invalid host %q, expected host or *.domain", host)
+		}
+	}
+
+	if cfg.Proxy.Bearer && cfg.Proxy.BearerCache < 0 {
+		v.add("proxy.bearer_cache", "must not be negative")
+	}
+
+	for _, header := range []struct {
+		key string
+		val string
+	}{
+		{"proxy.user_header", cfg.Proxy.UserHeader},
+		{"proxy.email_header", cfg.Proxy.EmailHeader},
+		{"proxy.groups_header", cfg.Proxy.GroupsHeader},
+		{"proxy.provider_header", cfg.Proxy.ProviderHeader},
+	} {
+		if header.val == "" {
+			v.add(header.key, "must not be empty")
+		}
+	}
+}
+
+// policy validates the rules, users and orgs must be scoped to a provider if
+// multiple providers are enabled as the names are only unique per provider.
+func (v *validator) policy(key string, policy Policy, scoped bool) {
+	for _, rules := range []struct {
+		key   string
+		rules Rules
+	}{
+		{key + ".allow", policy.Allow},
+		{key + ".deny", policy.Deny},
+	} {
+		names := make([]string, 0, len(rules.rules.Claims))
+
+		for name := range rules.rules.Claims {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			if len(rules.rules.Claims[name]) == 0 {
+				v.add(rules.key+".claims."+name, "at least one value is required")
+			}
+		}
+
+		if scoped {
+			for _, list := range []struct {
+				key  string
+				vals []string
+			}{
+				{rules.key + ".users", rules.rules.Users},
+				{rules.key + ".orgs", rules.rules.Orgs},
+			} {
+				for i, val := range list.vals {
+					if provider, _ := Scope(val); provider == "" {
+						v.add(fmt.Sprintf("%s[%d]", list.key, i), "%q must be scoped like github:%s if multiple providers are enabled", val, val)
+					}
+				}
+			}
+		}
+
+		for i, team := range rules.rules.Teams {
+			if strings.Count(team, "/") != 1 {
+				v.add(fmt.Sprintf("%s.teams[%d]", rules.key, i), "team %q must be in the form org/team", team)
+			}
+		}
+	}
+}
+
+func multiple(cfg *Config) bool {
+	enabled := 0
+
+	for _, val := range []bool{cfg.Gitlab.Enabled, cfg.GitHub.Enabled, cfg.Bitbucket.Enabled, cfg.OIDC.Enabled} {
+		if val {
+			enabled++
+		}
+	}
+
+	return enabled > 1
+}
+
+func (v *validator) check(key string, check Check) {
+	if check.Path != "" && !strings.HasPrefix(check.Path, "/") {
+		v.add(key+".path", "path %q must start with a slash", check.Path)
+	}
+
+	if check.Interval < 0 {
+		v.add(key+".interval", "must not be negative")
+	}
+
+	if check.Timeout < 0 {
+		v.add(key+".timeout", "must not be negative")
+	}
+
+	if check.Interval > 0 && check.Timeout > check.Interval {
+		v.add(key+".timeout", "must not exceed the interval")
+	}
+
+	if check.Healthy < 0 {
+		v.add(key+".healthy", "must not be negative")
+	}
+
+	if check.Unhealthy < 0 {
+		v.add(key+".unhealthy", "must not be negative")
+	}
+}
+
+func (v *validator) providers(cfg *Config) {
+	enabled := 0
+	bearer := cfg.Proxy.BearerProvider == ""
+
+	for _, p := range []struct {
+		key     string
+		enabled bool
+		client  string
+		secret  string
+	}{
+		{"gitlab", cfg.Gitlab.Enabled, cfg.Gitlab.Client, cfg.Gitlab.Secret},
+		{"github", cfg.GitHub.Enabled, cfg.GitHub.Client, cfg.GitHub.Secret},
+		{"bitbucket", cfg.Bitbucket.Enabled, cfg.Bitbucket.Client, cfg.Bitbucket.Secret},
+		{"oidc", cfg.OIDC.Enabled, cfg.OIDC.Client, cfg.OIDC.Secret},
+	} {
+		if !p.enabled {
+			continue
+		}
+
+		enabled++
+
+		if p.key == cfg.Proxy.BearerProvider {
+			bearer = true
+		}
+
+		if p.client == "" {
+			v.add(p.key+".client", "required if the provider is enabled")
+		}
+
+		if p.secret == "" {
+			v.add(p.key+".secret", "required if the provider is enabled")
+		}
+	}
+
+	if cfg.Gitlab.Enabled {
+		v.url("gitlab.url", cfg.Gitlab.URL)
+	}
+
+	if cfg.OIDC.Enabled {
+		v.url("oidc.issuer", cfg.OIDC.Issuer)
+
+		found := false
+
+		for _, scope := range cfg.OIDC.Scopes {
+			if scope == "openid" {
+				found = true
+			}
+		}
+
+		if !found {
+			v.add("oidc.scopes", "must include the openid scope")
+		}
+	}
+
+	if enabled == 0 {
+		v.add("providers", "at least one provider must be enabled")
+	}
+
+	if !bearer {
+		v.add("proxy.bearer_provider", "provider %q is not enabled", cfg.Proxy.BearerProvider)
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePolicyScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled int
+		policy  Policy
+		invalid []string
+		valid   []string
+	}{
+		{
+			name:    "single provider",
+			enabled: 1,
+			policy:  Policy{Allow: Rules{Users: []string{"alice"}, Orgs: []string{"acme"}}},
+			valid:   []string{"proxy.policy.allow.users[0]", "proxy.policy.allow.orgs[0]"},
+		},
+		{
+			name:    "multiple providers unscoped",
+			enabled: 2,
+			policy:  Policy{Allow: Rules{Users: []string{"alice"}}, Deny: Rules{Orgs: []string{"acme"}}},
+			invalid: []string{"proxy.policy.allow.users[0]", "proxy.policy.deny.orgs[0]"},
+		},
+		{
+			name:    "multiple providers scoped",
+			enabled: 2,
+			policy:  Policy{Allow: Rules{Users: []string{"github:alice"}, Orgs: []string{"gitlab:acme"}}},
+			valid:   []string{"proxy.policy.allow.users[0]", "proxy.policy.allow.orgs[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := New()
+			cfg.GitHub.Enabled = tt.enabled > 0
+			cfg.Gitlab.Enabled = tt.enabled > 1
+			cfg.Proxy.Policy = tt.policy
+
+			problems := Validate(cfg)
+
+			for _, key := range tt.invalid {
+				if !reported(problems, key) {
+					t.Errorf("expected problem for %s, got %v", key, problems)
+				}
+			}
+
+			for _, key := range tt.valid {
+				if reported(problems, key) {
+					t.Errorf("expected no problem for %s, got %v", key, problems)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSessionSecret(t *testing.T) {
+	tests := []struct {
+		store  string
+		secret string
+		valid  bool
+	}{
+		{"cookie", "", true},
+		{"memory", "", true},
+		{"file", "", false},
+		{"file", "secret", true},
+		{"redis", "", false},
+		{"redis", "secret", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.store+"/"+tt.secret, func(t *testing.T) {
+			cfg := New()
+			cfg.Session.Store = tt.store
+			cfg.Session.Secret = tt.secret
+
+			if got := !reported(Validate(cfg), "session.secret"); got != tt.valid {
+				t.Errorf("expected valid=%v, got %v", tt.valid, got)
+			}
+		})
+	}
+}
+
+func TestScope(t *testing.T) {
+	tests := []struct {
+		val      string
+		provider string
+		value    string
+	}{
+		{"alice", "", "alice"},
+		{"github:alice", "github", "alice"},
+		{"oidc:alice@example.com", "oidc", "alice@example.com"},
+		{"unknown:alice", "", "unknown:alice"},
+		{":alice", "", ":alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			provider, value := Scope(tt.val)
+
+			if provider != tt.provider || value != tt.value {
+				t.Errorf("expected %q and %q, got %q and %q", tt.provider, tt.value, provider, value)
+			}
+		})
+	}
+}
+
+func reported(problems []error, key string) bool {
+	for _, problem := range problems {
+		if strings.HasPrefix(problem.Error(), key+":") {
+			return true
+		}
+	}
+
+	return false
+}
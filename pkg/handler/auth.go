@@ -2,22 +2,232 @@ package handler
 
 import (
 	"net/http"
-	"path"
 
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/audit"
 	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/metrics"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
 )
 
-// Auth handles the callback from the OAuth2 provider.
-func Auth(cfg *config.Config) http.HandlerFunc {
+// Authorize verifies the CSRF token of the login form and starts the
+// authorization flow with a signed state bound to the browser.
+func Authorize(cfg *config.Config, sessions *session.Manager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		redirect := redirectTarget(cfg, r.PostFormValue("redirect"))
+		metrics.LoginAttempts.WithLabelValues(providerLabel(r)).Inc()
+
+		if err := sessions.VerifyCSRF(r, r.PostFormValue("csrf")); err != nil {
+			log.Info().
+				Err(err).
+				Str("provider", providerLabel(r)).
+				Msg("rejected login without valid csrf token")
+
+			metrics.LoginFailures.WithLabelValues(providerLabel(r), "csrf").Inc()
+
+			login(cfg, sessions, w, r, http.StatusForbidden, redirect, "The login form has expired, please try again.")
+			return
+		}
+
+		begin(cfg, sessions, w, r, redirect)
+	}
+}
+
+// begin issues the signed state and redirects to the provider selected by
+// the provider URL parameter.
+func begin(cfg *config.Config, sessions *session.Manager, w http.ResponseWriter, r *http.Request, redirect string) {
+	state, err := sessions.NewState(w, chi.URLParam(r, "provider"), redirect)
+
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to issue login state")
+
+		metrics.LoginFailures.WithLabelValues(providerLabel(r), "start").Inc()
+
+		login(cfg, sessions, w, r, http.StatusInternalServerError, redirect, "Failed to start the authentication.")
+		return
+	}
+
+	target, err := authURL(w, r, chi.URLParam(r, "provider"), state)
+
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to start authorization")
+
+		metrics.LoginFailures.WithLabelValues(providerLabel(r), "start").Inc()
+
+		login(cfg, sessions, w, r, http.StatusBadRequest, redirect, "Failed to start the authentication.")
+		return
+	}
+
+	http.Redirect(
+		w,
+		r,
+		target,
+		http.StatusSeeOther,
+	)
+}
+
+// Callback verifies the state and handles the callback from the provider.
+func Callback(cfg *config.Config, sessions *session.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := sessions.VerifyState(w, r, chi.URLParam(r, "provider"), r.URL.Query().Get("state"))
+
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("provider", providerLabel(r)).
+				Msg("rejected callback with invalid state")
+
+			metrics.LoginFailures.WithLabelValues(providerLabel(r), "state").Inc()
+
+			audit.Record(r, audit.Event{
+				Action:   audit.Login,
+				Decision: audit.Denied,
+				Reason:   "invalid or expired state",
+				Provider: providerLabel(r),
+			})
+
+			login(cfg, sessions, w, r, http.StatusBadRequest, "/", "The login request is invalid or has expired, please try again.")
+			return
+		}
+
+		redirect := redirectTarget(cfg, state.Redirect)
+
+		user, err := completeAuth(w, r, chi.URLParam(r, "provider"))
+
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("failed to complete authorization")
+
+			metrics.LoginFailures.WithLabelValues(providerLabel(r), "exchange").Inc()
+
+			audit.Record(r, audit.Event{
+				Action:   audit.Login,
+				Decision: audit.Failed,
+				Reason:   "failed to complete authorization",
+				Provider: providerLabel(r),
+			})
+
+			login(cfg, sessions, w, r, http.StatusUnauthorized, redirect, "Failed to complete the authentication.")
+			return
+		}
+
+		orgs, err := provider.Authorize(user.Provider, user.AccessToken)
+
+		if err != nil {
+			if err == provider.ErrNotMember {
+				log.Info().
+					Str("provider", user.Provider).
+					Str("username", user.NickName).
+					Strs("orgs", orgs).
+					Msg("denied access, not a member of allowed organizations")
+
+				metrics.OrgDenials.WithLabelValues(user.Provider).Inc()
+				metrics.LoginFailures.WithLabelValues(user.Provider, "membership").Inc()
+
+				audit.Record(r, audit.Event{
+					Action:   audit.Login,
+					Decision: audit.Denied,
+					Reason:   "not a member of allowed organizations",
+					Provider: user.Provider,
+					Username: user.NickName,
+				})
+
+				login(cfg, sessions, w, r, http.StatusForbidden, redirect, "You are not a member of an allowed organization.")
+				return
+			}
+
+			log.Warn().
+				Err(err).
+				Str("provider", user.Provider).
+				Msg("failed to fetch organizations")
+
+			metrics.LoginFailures.WithLabelValues(user.Provider, "organizations").Inc()
+
+			audit.Record(r, audit.Event{
+				Action:   audit.Login,
+				Decision: audit.Failed,
+				Reason:   "failed to fetch organizations",
+				Provider: user.Provider,
+				Username: user.NickName,
+			})
+
+			login(cfg, sessions, w, r, http.StatusBadGateway, redirect, "Failed to verify the organization membership.")
+			return
+		}
+
+		s := &session.Session{
+			Provider:      user.Provider,
+			UserID:        user.UserID,
+			Username:      user.NickName,
+			Email:         user.Email,
+			EmailVerified: provider.EmailVerified(user),
+			Name:          user.Name,
+			Orgs:          orgs,
+			Claims:        sessionClaims(cfg, user.RawData),
+			AccessToken:   user.AccessToken,
+			RefreshToken:  user.RefreshToken,
+			TokenExpiry:   user.ExpiresAt,
+			IDToken:       user.IDToken,
+		}
+
+		if err := sessions.Save(w, r, s); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("failed to store session")
+
+			metrics.LoginFailures.WithLabelValues(user.Provider, "session").Inc()
+
+			audit.Record(r, audit.Event{
+				Action:   audit.Login,
+				Decision: audit.Failed,
+				Reason:   "failed to store session",
+				Provider: user.Provider,
+				Username: user.NickName,
+			})
+
+			login(cfg, sessions, w, r, http.StatusInternalServerError, redirect, "Failed to store the session.")
+			return
+		}
+
+		log.Info().
+			Str("provider", s.Provider).
+			Str("username", s.Username).
+			Msg("successfully authenticated")
+
+		metrics.LoginSuccesses.WithLabelValues(s.Provider).Inc()
+
+		audit.Record(r, audit.Event{
+			Action:   audit.Login,
+			Decision: audit.Allowed,
+			Reason:   "successfully authenticated",
+			Provider: s.Provider,
+			Username: s.Username,
+		})
+
 		http.Redirect(
 			w,
 			r,
-			path.Join(
-				cfg.Server.Root,
-				"login",
-			),
-			http.StatusMovedPermanently,
+			redirect,
+			http.StatusFound,
 		)
 	}
 }
+
+// providerLabel returns the requested provider for metrics, unknown values
+// get grouped to keep the cardinality bounded.
+func providerLabel(r *http.Request) string {
+	name := chi.URLParam(r, "provider")
+
+	if _, err := provider.Get(name); err != nil {
+		return "unknown"
+	}
+
+	return name
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/markbates/goth/gothic"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+)
+
+func TestAuthorizeCSRF(t *testing.T) {
+	var calls int32
+
+	srv := tokenServer(&calls)
+	defer srv.Close()
+
+	cfg := refreshConfig(srv.URL)
+	cfg.Server.Templates = "../../templates"
+	provider.Use(provider.NewOIDC(cfg))
+	defer provider.Clear()
+
+	sessions := session.New(cfg, store.NewCookie())
+	gothic.Store = sessions.Store()
+
+	mux := chi.NewRouter()
+	mux.Post("/{provider}/auth", Authorize(cfg, sessions))
+
+	w := httptest.NewRecorder()
+	token, err := sessions.CSRF(w, httptest.NewRequest("GET", "/", nil))
+
+	if err != nil {
+		t.Fatalf("failed to issue csrf token: %s", err)
+	}
+
+	cookies := w.Result().Cookies()
+
+	tests := []struct {
+		name    string
+		csrf    string
+		cookies bool
+		status  int
+	}{
+		{"valid", token, true, http.StatusSeeOther},
+		{"missing token", "", true, http.StatusForbidden},
+		{"mismatched token", token + "x", true, http.StatusForbidden},
+		{"missing cookie", token, false, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"csrf": {tt.csrf}, "redirect": {"/wiki"}}
+			r := httptest.NewRequest("POST", "/oidc/auth", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if tt.cookies {
+				for _, cookie := range cookies {
+					r.AddCookie(cookie)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if tt.status != http.StatusSeeOther {
+				if !strings.Contains(w.Body.String(), "The login form has expired") {
+					t.Errorf("expected login form with error, got %s", w.Body.String())
+				}
+
+				return
+			}
+
+			if location := w.Header().Get("Location"); !strings.HasPrefix(location, srv.URL+"/auth?") {
+				t.Errorf("expected redirect to the provider, got %s", location)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/audit"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+)
+
+// bearerToken extracts a bearer token from the authorization header.
+func bearerToken(cfg *config.Config, r *http.Request) string {
+	if !cfg.Proxy.Bearer {
+		return ""
+	}
+
+	header := r.Header.Get("Authorization")
+
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+
+	return ""
+}
+
+// bearerSession validates the bearer token and builds a transient session.
+func bearerSession(cfg *config.Config, r *http.Request, token string) (*session.Session, int) {
+	user, orgs, err := provider.Bearer(token, cfg.Proxy.BearerProvider, cfg.Proxy.BearerCache)
+
+	switch {
+	case err == provider.ErrInvalidToken:
+		audit.Record(r, audit.Event{
+			Action:   audit.Token,
+			Decision: audit.Denied,
+			Reason:   "invalid token",
+		})
+
+		return nil, http.StatusUnauthorized
+	case err == provider.ErrNotMember:
+		log.Info().
+			Str("provider", user.Provider).
+			Str("username", user.NickName).
+			Strs("orgs", orgs).
+			Msg("denied bearer token, not a member of allowed organizations")
+
+		audit.Record(r, audit.Event{
+			Action:   audit.Token,
+			Decision: audit.Denied,
+			Reason:   "not a member of allowed organizations",
+			Provider: user.Provider,
+			Username: user.NickName,
+		})
+
+		return nil, http.StatusForbidden
+	case err != nil:
+		log.Warn().
+			Err(err).
+			Str("provider", user.Provider).
+			Msg("failed to validate bearer token")
+
+		audit.Record(r, audit.Event{
+			Action:   audit.Token,
+			Decision: audit.Failed,
+			Reason:   err.Error(),
+			Provider: user.Provider,
+		})
+
+		return nil, http.StatusBadGateway
+	}
+
+	audit.Record(r, audit.Event{
+		Action:   audit.Token,
+		Decision: audit.Allowed,
+		Reason:   "valid token",
+		Provider: user.Provider,
+		Username: user.NickName,
+	})
+
+	return &session.Session{
+		Provider:      user.Provider,
+		UserID:        user.UserID,
+		Username:      user.NickName,
+		Email:         user.Email,
+		EmailVerified: provider.EmailVerified(user),
+		Name:          user.Name,
+		Orgs:          orgs,
+		Claims:        sessionClaims(cfg, user.RawData),
+		AccessToken:   token,
+	}, http.StatusOK
+}
+
+// rejectBearer writes the error response for a rejected bearer token.
+func rejectBearer(w http.ResponseWriter, status int) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+
+	plain(w, status)
+}
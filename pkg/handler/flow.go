@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+)
+
+var (
+	// errStateMismatch gets returned if the callback state doesn't match the
+	// state of the authorization URL.
+	errStateMismatch = errors.New("state token mismatch")
+)
+
+// authURL begins the authorization at the provider and stores the provider
+// session for the callback. It follows gothic, but resolves the provider from
+// the provider registry which gets replaced atomically on reload.
+func authURL(w http.ResponseWriter, r *http.Request, name, state string) (string, error) {
+	p, err := provider.Auth(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	sess, err := p.BeginAuth(state)
+
+	if err != nil {
+		return "", err
+	}
+
+	target, err := sess.GetAuthURL()
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := gothic.StoreInSession(name, sess.Marshal(), r, w); err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+// completeAuth exchanges the authorization code of the callback and fetches
+// the user from the provider.
+func completeAuth(w http.ResponseWriter, r *http.Request, name string) (goth.User, error) {
+	p, err := provider.Auth(name)
+
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	value, err := gothic.GetFromSession(name, r)
+
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	defer gothic.Logout(w, r)
+
+	sess, err := p.UnmarshalSession(value)
+
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	if err := matchState(sess, r.URL.Query().Get("state")); err != nil {
+		return goth.User{}, err
+	}
+
+	if user, err := p.FetchUser(sess); err == nil {
+		return user, nil
+	}
+
+	if _, err := sess.Authorize(p, r.URL.Query()); err != nil {
+		return goth.User{}, err
+	}
+
+	return p.FetchUser(sess)
+}
+
+func matchState(sess goth.Session, state string) error {
+	raw, err := sess.GetAuthURL()
+
+	if err != nil {
+		return err
+	}
+
+	target, err := url.Parse(raw)
+
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(target.Query().Get("state")), []byte(state)) != 1 {
+		return errStateMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+)
+
+// identityHeaders maps the configured header names to the session values.
+func identityHeaders(cfg *config.Config, s *session.Session) http.Header {
+	headers := http.Header{}
+
+	set := func(name, value string) {
+		if name == "" || value == "" {
+			return
+		}
+
+		headers.Set(name, strings.NewReplacer("\r", "", "\n", "").Replace(value))
+	}
+
+	set(cfg.Proxy.UserHeader, s.Username)
+	set(cfg.Proxy.EmailHeader, s.Email)
+	set(cfg.Proxy.NameHeader, s.Name)
+	set(cfg.Proxy.GroupsHeader, strings.Join(s.Orgs, ","))
+	set(cfg.Proxy.ProviderHeader, s.Provider)
+
+	if cfg.Proxy.PassToken {
+		set(cfg.Proxy.TokenHeader, s.AccessToken)
+	}
+
+	return headers
+}
+
+// stripIdentity removes any identity headers sent by the client, header
+// names are compared ignoring the difference between dashes and underscores.
+// Keys get deleted as received, Del would canonicalize them first.
+func stripIdentity(cfg *config.Config, r *http.Request) {
+	names := map[string]bool{}
+
+	for _, name := range []string{
+		cfg.Proxy.UserHeader,
+		cfg.Proxy.EmailHeader,
+		cfg.Proxy.NameHeader,
+		cfg.Proxy.GroupsHeader,
+		cfg.Proxy.ProviderHeader,
+		cfg.Proxy.TokenHeader,
+		cfg.JWT.Header,
+	} {
+		if name != "" {
+			names[normalizeHeader(name)] = true
+		}
+	}
+
+	for name := range r.Header {
+		if names[normalizeHeader(name)] {
+			delete(r.Header, name)
+		}
+	}
+}
+
+// forwardIdentity sets the identity headers on the proxied request.
+func forwardIdentity(cfg *config.Config, signer *jwt.Signer, r *http.Request, s *session.Session) error {
+	for name, values := range identityHeaders(cfg, s) {
+		r.Header[name] = values
+	}
+
+	if signer == nil || cfg.JWT.Header == "" {
+		return nil
+	}
+
+	assertion, err := signAssertion(cfg, signer, s)
+
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set(cfg.JWT.Header, assertion)
+	return nil
+}
+
+// signAssertion mints a short-lived token containing the user claims.
+func signAssertion(cfg *config.Config, signer *jwt.Signer, s *session.Session) (string, error) {
+	now := time.Now()
+
+	claims := jwt.Claims{
+		"iss":                cfg.Server.Host,
+		"sub":                s.Username,
+		"iat":                now.Unix(),
+		"nbf":                now.Unix(),
+		"exp":                now.Add(cfg.JWT.Lifetime).Unix(),
+		"preferred_username": s.Username,
+		"provider":           s.Provider,
+	}
+
+	if s.Email != "" {
+		claims["email"] = s.Email
+	}
+
+	if s.Name != "" {
+		claims["name"] = s.Name
+	}
+
+	if len(s.Orgs) > 0 {
+		claims["groups"] = s.Orgs
+	}
+
+	return signer.Sign(claims)
+}
+
+func normalizeHeader(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "-", -1))
+}
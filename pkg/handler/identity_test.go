@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+)
+
+func identityConfig() *config.Config {
+	cfg := config.New()
+	cfg.Server.Host = "https://auth.example.com"
+	cfg.Proxy.UserHeader = "X-Forwarded-User"
+	cfg.Proxy.EmailHeader = "X-Forwarded-Email"
+	cfg.Proxy.NameHeader = "X-Forwarded-Name"
+	cfg.Proxy.GroupsHeader = "X-Forwarded-Groups"
+	cfg.Proxy.ProviderHeader = "X-Forwarded-Provider"
+	cfg.Proxy.TokenHeader = "X-Forwarded-Access-Token"
+	cfg.JWT.Header = "X-Forwarded-Assertion"
+	cfg.JWT.Lifetime = time.Minute
+
+	return cfg
+}
+
+func TestStripIdentity(t *testing.T) {
+	cfg := identityConfig()
+
+	tests := []struct {
+		header   string
+		stripped bool
+	}{
+		{"X-Forwarded-User", true},
+		{"x-forwarded-user", true},
+		{"X-FORWARDED-EMAIL", true},
+		{"X_Forwarded_User", true},
+		{"x_forwarded_groups", true},
+		{"X-Forwarded_Provider", true},
+		{"X_FORWARDED-ACCESS_TOKEN", true},
+		{"X-Forwarded-Name", true},
+		{"X-Forwarded-Assertion", true},
+		{"x_forwarded_assertion", true},
+		{"X-Forwarded-Users", false},
+		{"X-Forwarded-For", false},
+		{"Authorization", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+
+			// bypass canonicalization like underscore headers from the wire
+			r.Header[tt.header] = []string{"spoofed"}
+
+			stripIdentity(cfg, r)
+
+			if _, ok := r.Header[tt.header]; ok == tt.stripped {
+				t.Errorf("expected stripped=%v, got %v", tt.stripped, r.Header)
+			}
+		})
+	}
+}
+
+func TestIdentityHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		session session.Session
+		token   bool
+		want    http.Header
+	}{
+		{
+			name:    "all values",
+			session: session.Session{Provider: "github", Username: "jdoe", Email: "jdoe@example.com", Name: "John Doe", Orgs: []string{"acme", "umbrella"}, AccessToken: "secret"},
+			want: http.Header{
+				"X-Forwarded-User":     {"jdoe"},
+				"X-Forwarded-Email":    {"jdoe@example.com"},
+				"X-Forwarded-Name":     {"John Doe"},
+				"X-Forwarded-Groups":   {"acme,umbrella"},
+				"X-Forwarded-Provider": {"github"},
+			},
+		},
+		{
+			name:    "access token",
+			session: session.Session{Provider: "github", Username: "jdoe", AccessToken: "secret"},
+			token:   true,
+			want: http.Header{
+				"X-Forwarded-User":         {"jdoe"},
+				"X-Forwarded-Provider":     {"github"},
+				"X-Forwarded-Access-Token": {"secret"},
+			},
+		},
+		{
+			name:    "line breaks",
+			session: session.Session{Provider: "oidc", Username: "jdoe\r\nX-Admin: true", Name: "John\nDoe", Orgs: []string{"acme\r", "\nops"}},
+			want: http.Header{
+				"X-Forwarded-User":     {"jdoeX-Admin: true"},
+				"X-Forwarded-Name":     {"JohnDoe"},
+				"X-Forwarded-Groups":   {"acme,ops"},
+				"X-Forwarded-Provider": {"oidc"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := identityConfig()
+			cfg.Proxy.PassToken = tt.token
+
+			if got := identityHeaders(cfg, &tt.session); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("disabled headers", func(t *testing.T) {
+		cfg := identityConfig()
+		cfg.Proxy.EmailHeader = ""
+
+		if got := identityHeaders(cfg, &session.Session{Username: "jdoe", Email: "jdoe@example.com"}); len(got) != 1 || got.Get("X-Forwarded-User") != "jdoe" {
+			t.Errorf("expected only the user header, got %v", got)
+		}
+	})
+}
+
+func TestForwardIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+
+	if err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	signer, err := jwt.LoadSigner(dir, "signing.pem", "RS256")
+
+	if err != nil {
+		t.Fatalf("failed to load signer: %s", err)
+	}
+
+	cfg := identityConfig()
+	s := &session.Session{Provider: "github", Username: "jdoe", Email: "jdoe@example.com", Orgs: []string{"acme"}}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Forwarded-Assertion", "spoofed")
+	r.Header["X_Forwarded_User"] = []string{"admin"}
+
+	stripIdentity(cfg, r)
+
+	if err := forwardIdentity(cfg, signer, r, s); err != nil {
+		t.Fatalf("failed to forward identity: %s", err)
+	}
+
+	if _, ok := r.Header["X_Forwarded_User"]; ok || r.Header.Get("X-Forwarded-User") != "jdoe" {
+		t.Errorf("expected spoofed user to be replaced, got %v", r.Header)
+	}
+
+	token, err := jwt.Parse(r.Header.Get("X-Forwarded-Assertion"))
+
+	if err != nil {
+		t.Fatalf("failed to parse assertion: %s", err)
+	}
+
+	key, err := signer.JWKS().Keys[0].PublicKey()
+
+	if err != nil {
+		t.Fatalf("failed to load public key: %s", err)
+	}
+
+	if err := token.Verify(key); err != nil {
+		t.Errorf("expected valid signature, got %s", err)
+	}
+
+	if err := token.Validate(0); err != nil {
+		t.Errorf("expected valid token, got %s", err)
+	}
+
+	if token.Header.KeyID != signer.JWKS().Keys[0].KeyID {
+		t.Errorf("expected key id %q, got %q", signer.JWKS().Keys[0].KeyID, token.Header.KeyID)
+	}
+
+	for name, want := range map[string]string{"iss": "https://auth.example.com", "sub": "jdoe", "email": "jdoe@example.com", "provider": "github"} {
+		if got := token.Claims.String(name); got != want {
+			t.Errorf("expected claim %s to be %q, got %q", name, want, got)
+		}
+	}
+
+	if groups := token.Claims.Strings("groups"); !reflect.DeepEqual(groups, []string{"acme"}) {
+		t.Errorf("expected groups claim, got %v", groups)
+	}
+
+	if lifetime := token.Claims.Time("exp").Sub(token.Claims.Time("iat")); lifetime != time.Minute {
+		t.Errorf("expected lifetime of a minute, got %s", lifetime)
+	}
+
+	t.Run("without signer", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+
+		if err := forwardIdentity(cfg, nil, r, s); err != nil {
+			t.Fatalf("failed to forward identity: %s", err)
+		}
+
+		if r.Header.Get("X-Forwarded-Assertion") != "" {
+			t.Errorf("expected no assertion, got %q", r.Header.Get("X-Forwarded-Assertion"))
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+)
+
+// JWKS publishes the public keys to verify the signed assertions.
+func JWKS(cfg *config.Config, signer *jwt.Signer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(signer.JWKS()); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("failed to encode key set")
+		}
+	}
+}
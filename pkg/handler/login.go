@@ -1,30 +1,104 @@
 package handler
 
 import (
+	"bytes"
 	"net/http"
+	"path"
 
 	"github.com/rs/zerolog/log"
 	"github.com/webhippie/fail"
 	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
 	"github.com/webhippie/oauth2-proxy/pkg/templates"
 )
 
-// Login displays the login form for authentication.
-func Login(cfg *config.Config) http.HandlerFunc {
+// LoginPage defines the view model of the login template.
+type LoginPage struct {
+	Title     string
+	Root      string
+	Redirect  string
+	Error     string
+	CSRF      string
+	Auto      bool
+	Providers []LoginProvider
+}
+
+// LoginProvider defines a provider button of the login template.
+type LoginProvider struct {
+	Name     string
+	Label    string
+	Icon     string
+	AuthURL  string
+	Redirect string
+}
+
+// Login displays the login form for authentication, if only a single
+// provider is enabled the form gets submitted automatically.
+func Login(cfg *config.Config, sessions *session.Manager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := map[string]string{
-			"Title": cfg.Proxy.Title,
-			"Root":  cfg.Server.Root,
-			"Error": "",
-		}
+		login(cfg, sessions, w, r, http.StatusOK, redirectTarget(cfg, r.URL.Query().Get("redirect")), "")
+	}
+}
+
+// login renders the login form including the CSRF token of the browser. The
+// form of a single provider gets submitted automatically unless an error is
+// shown, this way the authorization always passes the CSRF verification.
+func login(cfg *config.Config, sessions *session.Manager, w http.ResponseWriter, r *http.Request, status int, redirect, message string) {
+	token, err := sessions.CSRF(w, r)
+
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to issue csrf token")
+
+		fail.ErrorPlain(w, fail.Cause(err).Unexpected())
+		return
+	}
+
+	buf := bytes.NewBuffer(nil)
 
-		if err := templates.Load(cfg).ExecuteTemplate(w, "login.tmpl", vars); err != nil {
-			log.Warn().
-				Err(err).
-				Msg("failed to process login template")
+	if err := templates.Load(cfg).ExecuteTemplate(buf, "login.tmpl", loginPage(cfg, token, redirect, message)); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to process login template")
 
-			fail.ErrorPlain(w, fail.Cause(err).Unexpected())
-			return
+		fail.ErrorPlain(w, fail.Cause(err).Unexpected())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	buf.WriteTo(w)
+}
+
+// loginPage builds the view model of the login template from the enabled
+// providers, the form of a single provider gets submitted automatically.
+func loginPage(cfg *config.Config, token, redirect, message string) LoginPage {
+	page := LoginPage{
+		Title:    cfg.Proxy.Title,
+		Root:     cfg.Server.Root,
+		Redirect: redirect,
+		Error:    message,
+		CSRF:     token,
+	}
+
+	for _, p := range provider.List() {
+		entry := LoginProvider{
+			Name:     p.Name(),
+			Label:    p.Label(),
+			AuthURL:  path.Join("/", cfg.Server.Root, p.Name(), "auth"),
+			Redirect: redirect,
+		}
+
+		if icon := p.Icon(); icon != "" {
+			entry.Icon = path.Join("/", cfg.Server.Root, "assets", icon)
 		}
+
+		page.Providers = append(page.Providers, entry)
 	}
+
+	page.Auto = len(page.Providers) == 1 && message == ""
+	return page
 }
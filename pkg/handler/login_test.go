@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+)
+
+func TestLoginPage(t *testing.T) {
+	defer provider.Clear()
+
+	tests := []struct {
+		name      string
+		root      string
+		providers []provider.Provider
+		message   string
+		auto      bool
+		want      []LoginProvider
+	}{
+		{
+			name:      "single provider",
+			root:      "/oauth2-proxy",
+			providers: []provider.Provider{provider.NewGitHub(config.New())},
+			auto:      true,
+			want: []LoginProvider{
+				{Name: "github", Label: "GitHub", Icon: "/oauth2-proxy/assets/github.svg", AuthURL: "/oauth2-proxy/github/auth", Redirect: "/wiki"},
+			},
+		},
+		{
+			name:      "single provider with error",
+			root:      "/oauth2-proxy",
+			providers: []provider.Provider{provider.NewGitHub(config.New())},
+			message:   "The login form has expired, please try again.",
+			want: []LoginProvider{
+				{Name: "github", Label: "GitHub", Icon: "/oauth2-proxy/assets/github.svg", AuthURL: "/oauth2-proxy/github/auth", Redirect: "/wiki"},
+			},
+		},
+		{
+			name:      "multiple providers",
+			root:      "/oauth2-proxy",
+			providers: []provider.Provider{provider.NewGitlab(config.New()), provider.NewGitHub(config.New())},
+			want: []LoginProvider{
+				{Name: "github", Label: "GitHub", Icon: "/oauth2-proxy/assets/github.svg", AuthURL: "/oauth2-proxy/github/auth", Redirect: "/wiki"},
+				{Name: "gitlab", Label: "Gitlab", Icon: "/oauth2-proxy/assets/gitlab.svg", AuthURL: "/oauth2-proxy/gitlab/auth", Redirect: "/wiki"},
+			},
+		},
+		{
+			name:      "slash root",
+			root:      "/",
+			providers: []provider.Provider{provider.NewGitHub(config.New())},
+			auto:      true,
+			want: []LoginProvider{
+				{Name: "github", Label: "GitHub", Icon: "/assets/github.svg", AuthURL: "/github/auth", Redirect: "/wiki"},
+			},
+		},
+		{
+			name:      "empty root",
+			root:      "",
+			providers: []provider.Provider{provider.NewGitHub(config.New())},
+			auto:      true,
+			want: []LoginProvider{
+				{Name: "github", Label: "GitHub", Icon: "/assets/github.svg", AuthURL: "/github/auth", Redirect: "/wiki"},
+			},
+		},
+		{
+			name: "without providers",
+			root: "/oauth2-proxy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.Clear()
+			provider.Use(tt.providers...)
+
+			cfg := config.New()
+			cfg.Server.Root = tt.root
+
+			page := loginPage(cfg, "token", "/wiki", tt.message)
+
+			if page.Auto != tt.auto {
+				t.Errorf("expected auto=%v, got %v", tt.auto, page.Auto)
+			}
+
+			if page.CSRF != "token" || page.Error != tt.message || page.Root != tt.root {
+				t.Errorf("expected csrf, error and root to be passed, got %+v", page)
+			}
+
+			if !reflect.DeepEqual(page.Providers, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, page.Providers)
+			}
+		})
+	}
+}
+
+func TestLoginAutoSubmit(t *testing.T) {
+	defer provider.Clear()
+
+	cfg := config.New()
+	cfg.Server.Root = "/oauth2-proxy"
+	cfg.Server.Templates = "../../templates"
+
+	sessions := session.New(cfg, store.NewCookie())
+
+	tests := []struct {
+		name      string
+		providers []provider.Provider
+		auto      bool
+	}{
+		{"single provider", []provider.Provider{provider.NewGitHub(cfg)}, true},
+		{"multiple providers", []provider.Provider{provider.NewGitHub(cfg), provider.NewGitlab(cfg)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.Clear()
+			provider.Use(tt.providers...)
+
+			w := httptest.NewRecorder()
+			Login(cfg, sessions).ServeHTTP(w, httptest.NewRequest("GET", "/oauth2-proxy/login?redirect=/wiki", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+
+			body := w.Body.String()
+
+			if !strings.Contains(body, `action="/oauth2-proxy/github/auth"`) || !strings.Contains(body, `name="csrf" value="`) {
+				t.Errorf("expected csrf protected provider form, got %s", body)
+			}
+
+			if got := strings.Contains(body, "document.forms[0].submit()"); got != tt.auto {
+				t.Errorf("expected auto submit=%v, got %v", tt.auto, got)
+			}
+		})
+	}
+}
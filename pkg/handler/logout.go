@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/fail"
+	"github.com/webhippie/oauth2-proxy/pkg/audit"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/templates"
+)
+
+// LogoutPage defines the view model of the logout template.
+type LogoutPage struct {
+	Title   string
+	Root    string
+	Error   string
+	CSRF    string
+	Confirm bool
+}
+
+// Logout asks signed in users to confirm the logout, the session only gets
+// cleared by submitting the form to prevent cross-site logout requests.
+func Logout(cfg *config.Config, sessions *session.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, err := sessions.Load(r)
+		logout(cfg, sessions, w, r, http.StatusOK, err == nil, "")
+	}
+}
+
+// SignOut verifies the CSRF token of the logout form, clears the session,
+// optionally revokes the provider token and terminates the session at the
+// provider if supported.
+func SignOut(cfg *config.Config, sessions *session.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := sessions.VerifyCSRF(r, r.PostFormValue("csrf")); err != nil {
+			log.Info().
+				Err(err).
+				Msg("rejected logout without valid csrf token")
+
+			logout(cfg, sessions, w, r, http.StatusForbidden, true, "The logout form has expired, please try again.")
+			return
+		}
+
+		s, err := sessions.Load(r)
+		sessions.Clear(w, r)
+
+		if err != nil {
+			logout(cfg, sessions, w, r, http.StatusOK, false, "")
+			return
+		}
+
+		if cfg.Logout.Revoke && s.AccessToken != "" {
+			if err := provider.Revoke(s.Provider, s.AccessToken); err != nil && err != provider.ErrNotSupported {
+				log.Warn().
+					Err(err).
+					Str("provider", s.Provider).
+					Msg("failed to revoke access token")
+			}
+		}
+
+		log.Info().
+			Str("provider", s.Provider).
+			Str("username", s.Username).
+			Msg("successfully signed out")
+
+		audit.Record(r, audit.Event{
+			Action:   audit.Logout,
+			Decision: audit.Allowed,
+			Reason:   "signed out",
+			Provider: s.Provider,
+			Username: s.Username,
+		})
+
+		target, err := provider.EndSession(s.Provider, s.IDToken, logoutRedirect(cfg))
+
+		if err != nil {
+			if err != provider.ErrNotSupported {
+				log.Warn().
+					Err(err).
+					Str("provider", s.Provider).
+					Msg("failed to end session at provider")
+			}
+
+			logout(cfg, sessions, w, r, http.StatusOK, false, "")
+			return
+		}
+
+		http.Redirect(
+			w,
+			r,
+			target,
+			http.StatusSeeOther,
+		)
+	}
+}
+
+// logout renders the logout confirmation including the CSRF token of the
+// browser, or the signed out page if there is nothing to confirm.
+func logout(cfg *config.Config, sessions *session.Manager, w http.ResponseWriter, r *http.Request, status int, confirm bool, message string) {
+	page := LogoutPage{
+		Title:   cfg.Proxy.Title,
+		Root:    cfg.Server.Root,
+		Error:   message,
+		Confirm: confirm,
+	}
+
+	if confirm {
+		token, err := sessions.CSRF(w, r)
+
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("failed to issue csrf token")
+
+			fail.ErrorPlain(w, fail.Cause(err).Unexpected())
+			return
+		}
+
+		page.CSRF = token
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := templates.Load(cfg).ExecuteTemplate(buf, "logout.tmpl", page); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to process logout template")
+
+		fail.ErrorPlain(w, fail.Cause(err).Unexpected())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	buf.WriteTo(w)
+}
+
+// logoutRedirect defines where the provider redirects after terminating the
+// session, by default the logout page of the proxy.
+func logoutRedirect(cfg *config.Config) string {
+	if cfg.Logout.Redirect != "" {
+		return cfg.Logout.Redirect
+	}
+
+	return cfg.Server.Host + cfg.Server.Root + "/logout"
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+)
+
+func TestLogout(t *testing.T) {
+	var (
+		srv     *httptest.Server
+		mutex   sync.Mutex
+		revoked []string
+	)
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":               srv.URL,
+				"jwks_uri":             srv.URL + "/jwks",
+				"end_session_endpoint": srv.URL + "/end",
+				"revocation_endpoint":  srv.URL + "/revoke",
+			})
+		case "/revoke":
+			r.ParseForm()
+
+			mutex.Lock()
+			revoked = append(revoked, r.PostForm.Get("token"))
+			mutex.Unlock()
+		}
+	}))
+
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.Server.Host = "https://auth.example.com"
+	cfg.Server.Root = "/oauth2"
+	cfg.Server.Templates = "../../templates"
+	cfg.Session.Name = "_session"
+	cfg.Session.Lifetime = time.Hour
+	cfg.OIDC.Issuer = srv.URL
+	cfg.OIDC.Client = "client"
+	cfg.Logout.Revoke = true
+
+	provider.Use(provider.NewOIDC(cfg))
+	defer provider.Clear()
+
+	sessions := session.New(cfg, store.NewMemory(10, 0))
+
+	signIn := func(t *testing.T) ([]*http.Cookie, string) {
+		w := httptest.NewRecorder()
+
+		if err := sessions.Save(w, httptest.NewRequest("GET", "/", nil), &session.Session{Provider: "oidc", Username: "jdoe", AccessToken: "access", IDToken: "id"}); err != nil {
+			t.Fatalf("failed to save session: %s", err)
+		}
+
+		token, err := sessions.CSRF(w, httptest.NewRequest("GET", "/", nil))
+
+		if err != nil {
+			t.Fatalf("failed to issue csrf token: %s", err)
+		}
+
+		return w.Result().Cookies(), token
+	}
+
+	request := func(method string, cookies []*http.Cookie, csrf string) *http.Request {
+		form := url.Values{"csrf": {csrf}}
+
+		r := httptest.NewRequest(method, "/oauth2/logout", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+
+		return r
+	}
+
+	t.Run("confirm", func(t *testing.T) {
+		cookies, _ := signIn(t)
+
+		w := httptest.NewRecorder()
+		Logout(cfg, sessions).ServeHTTP(w, request("GET", cookies, ""))
+
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `action="/oauth2/logout"`) || !strings.Contains(w.Body.String(), `name="csrf"`) {
+			t.Fatalf("expected confirmation form, got %d: %s", w.Code, w.Body.String())
+		}
+
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == "_session" {
+				t.Errorf("expected session to be kept, got %v", cookie)
+			}
+		}
+	})
+
+	t.Run("signed out", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Logout(cfg, sessions).ServeHTTP(w, request("GET", nil, ""))
+
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "You have been signed out") {
+			t.Errorf("expected signed out page, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	tests := []struct {
+		name    string
+		csrf    func(token string) string
+		status  int
+		cleared bool
+	}{
+		{"missing token", func(token string) string { return "" }, http.StatusForbidden, false},
+		{"mismatched token", func(token string) string { return token + "x" }, http.StatusForbidden, false},
+		{"valid token", func(token string) string { return token }, http.StatusSeeOther, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutex.Lock()
+			revoked = nil
+			mutex.Unlock()
+
+			cookies, token := signIn(t)
+			r := request("POST", cookies, tt.csrf(token))
+
+			w := httptest.NewRecorder()
+			SignOut(cfg, sessions).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if _, err := sessions.Load(r); (err != nil) != tt.cleared {
+				t.Errorf("expected cleared=%v, got %v", tt.cleared, err)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if !tt.cleared {
+				if len(revoked) != 0 {
+					t.Errorf("expected no revocation, got %v", revoked)
+				}
+
+				return
+			}
+
+			if len(revoked) != 1 || revoked[0] != "access" {
+				t.Errorf("expected access token to be revoked, got %v", revoked)
+			}
+
+			target, err := url.Parse(w.Header().Get("Location"))
+
+			if err != nil || !strings.HasPrefix(target.String(), srv.URL+"/end?") {
+				t.Fatalf("expected redirect to end session, got %s", w.Header().Get("Location"))
+			}
+
+			if hint := target.Query().Get("id_token_hint"); hint != "id" {
+				t.Errorf("expected id token hint, got %q", hint)
+			}
+
+			if redirect := target.Query().Get("post_logout_redirect_uri"); redirect != "https://auth.example.com/oauth2/logout" {
+				t.Errorf("expected redirect back to the logout page, got %q", redirect)
+			}
+		})
+	}
+}
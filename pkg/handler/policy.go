@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/fail"
+	"github.com/webhippie/oauth2-proxy/pkg/audit"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/templates"
+)
+
+// forbidden displays the error page for requests denied by a route policy.
+func forbidden(cfg *config.Config, w http.ResponseWriter, s *session.Session) {
+	vars := map[string]string{
+		"Title":    cfg.Proxy.Title,
+		"Root":     cfg.Server.Root,
+		"Username": s.Username,
+		"Provider": s.Provider,
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := templates.Load(cfg).ExecuteTemplate(buf, "forbidden.tmpl", vars); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to process forbidden template")
+
+		fail.ErrorPlain(w, fail.Cause(err).Unexpected())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+
+	buf.WriteTo(w)
+}
+
+// denied logs and records a request denied by a route policy.
+func denied(r *http.Request, route, rule string, err error, s *session.Session) {
+	log.Info().
+		Err(err).
+		Str("route", route).
+		Str("provider", s.Provider).
+		Str("username", s.Username).
+		Str("rule", rule).
+		Msg("denied access by route policy")
+
+	audit.Record(r, audit.Event{
+		Action:   audit.Access,
+		Decision: audit.Denied,
+		Reason:   reason(err, rule),
+		Provider: s.Provider,
+		Username: s.Username,
+		Route:    route,
+	})
+}
+
+// reason describes the policy decision including the matched rule.
+func reason(err error, rule string) string {
+	if rule == "" {
+		return err.Error()
+	}
+
+	return err.Error() + " by rule " + rule
+}
+
+// sessionClaims extracts the claims referenced by any policy, other claims
+// are dropped to keep the session small.
+func sessionClaims(cfg *config.Config, raw map[string]interface{}) map[string][]string {
+	names := policy.Claims(cfg)
+
+	if len(names) == 0 || len(raw) == 0 {
+		return nil
+	}
+
+	result := make(map[string][]string, len(names))
+
+	for _, name := range names {
+		if vals := jwt.Claims(raw).Strings(name); len(vals) > 0 {
+			result[name] = vals
+		}
+	}
+
+	return result
+}
@@ -2,28 +2,105 @@ package handler
 
 import (
 	"net/http"
+	"net/url"
 	"path"
 
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/fail"
 	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/upstream"
 )
 
-// Proxy redirects to login or proxies the requests.
-func Proxy(cfg *config.Config, proxy http.Handler) http.HandlerFunc {
+// Proxy redirects to login or proxies the requests, requests matching a
+// skip-auth rule get proxied without a session.
+func Proxy(cfg *config.Config, sessions *session.Manager, signer *jwt.Signer, skip *policy.Skip, proxy *upstream.Table) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		r.Header.Add(cfg.Proxy.UserHeader, "myuser")
+		stripIdentity(cfg, r)
 
-		// TODO: check if user is authenticated
+		if rule := skip.Match(r); rule != "" {
+			log.Debug().
+				Str("rule", rule).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Msg("skipped authentication")
 
-		http.Redirect(
-			w,
-			r,
-			path.Join(
-				cfg.Server.Root,
-				"login",
-			),
-			http.StatusMovedPermanently,
-		)
+			proxy.ServeHTTP(w, r)
+			return
+		}
 
-		// proxy.ServeHTTP(w, r)
+		if token := bearerToken(cfg, r); token != "" {
+			s, status := bearerSession(cfg, r, token)
+
+			if s == nil {
+				rejectBearer(w, status)
+				return
+			}
+
+			forward(cfg, signer, proxy, w, r, s)
+			return
+		}
+
+		s, err := sessions.Load(r)
+
+		if err == nil {
+			s, err = renew(cfg, sessions, w, r, s)
+		}
+
+		if err == nil {
+			s, err = revalidate(cfg, sessions, w, r, s)
+		}
+
+		if err != nil {
+			if err != session.ErrMissing {
+				log.Debug().
+					Err(err).
+					Msg("failed to load session")
+
+				sessions.Clear(w, r)
+			}
+
+			http.Redirect(
+				w,
+				r,
+				path.Join(
+					cfg.Server.Root,
+					"login",
+				)+"?redirect="+url.QueryEscape(originalTarget(cfg, r)),
+				http.StatusFound,
+			)
+
+			return
+		}
+
+		forward(cfg, signer, proxy, w, r, s)
 	}
 }
+
+func forward(cfg *config.Config, signer *jwt.Signer, proxy *upstream.Table, w http.ResponseWriter, r *http.Request, s *session.Session) {
+	u := proxy.Match(r)
+
+	if u == nil {
+		proxy.ServeHTTP(w, r)
+		return
+	}
+
+	if rule, err := u.Policy.Evaluate(s); err != nil {
+		denied(r, u.Route.Name, rule, err, s)
+		forbidden(cfg, w, s)
+		return
+	}
+
+	if err := forwardIdentity(cfg, signer, r, s); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to sign assertion")
+
+		fail.ErrorPlain(w, fail.Cause(err).Unexpected())
+		return
+	}
+
+	u.ServeHTTP(w, r)
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+	"github.com/webhippie/oauth2-proxy/pkg/templates"
+	"github.com/webhippie/oauth2-proxy/pkg/upstream"
+)
+
+const (
+	// ReadyOK marks a passed readiness check.
+	ReadyOK = "ok"
+
+	// ReadyFailed marks a failed readiness check.
+	ReadyFailed = "failed"
+
+	// ReadySkipped marks a readiness check which doesn't apply.
+	ReadySkipped = "skipped"
+)
+
+var (
+	// errSkipped signals that a readiness check doesn't apply.
+	errSkipped = errors.New("skipped")
+)
+
+// Readiness defines the result of all readiness checks.
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]ReadyResult `json:"checks"`
+}
+
+// ReadyResult defines the result of a single readiness check.
+type ReadyResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Ready verifies all dependencies and renders a breakdown of every check.
+func Ready(cfg *config.Config, storage store.SessionStore, proxy *upstream.Table) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result := Readiness{
+			Status: ReadyOK,
+			Checks: map[string]ReadyResult{},
+		}
+
+		for name, check := range map[string]func() (string, error){
+			"providers":     readyProviders,
+			"session_store": func() (string, error) { return readyStore(storage) },
+			"templates":     func() (string, error) { return readyTemplates(cfg) },
+			"certificates":  func() (string, error) { return readyCertificates(cfg) },
+			"upstreams":     func() (string, error) { return readyUpstreams(proxy) },
+		} {
+			msg, err := check()
+
+			switch {
+			case err == errSkipped:
+				result.Checks[name] = ReadyResult{
+					Status:  ReadySkipped,
+					Message: msg,
+				}
+			case err != nil:
+				result.Status = ReadyFailed
+				result.Checks[name] = ReadyResult{
+					Status:  ReadyFailed,
+					Message: err.Error(),
+				}
+			default:
+				result.Checks[name] = ReadyResult{
+					Status:  ReadyOK,
+					Message: msg,
+				}
+			}
+		}
+
+		status := http.StatusOK
+
+		if result.Status != ReadyOK {
+			status = http.StatusServiceUnavailable
+
+			log.Warn().
+				Interface("checks", result.Checks).
+				Msg("readiness check failed")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("failed to encode readiness")
+		}
+	}
+}
+
+func readyProviders() (string, error) {
+	providers := provider.List()
+
+	if len(providers) == 0 {
+		return "", errors.New("no provider configured")
+	}
+
+	return fmt.Sprintf("%d providers configured", len(providers)), nil
+}
+
+func readyStore(storage store.SessionStore) (string, error) {
+	if err := storage.Ping(); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+func readyTemplates(cfg *config.Config) (string, error) {
+	if _, err := templates.Parse(cfg); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+func readyCertificates(cfg *config.Config) (string, error) {
+	if cfg.Server.AutoCert {
+		return "managed by autocert", errSkipped
+	}
+
+	if cfg.Server.Cert == "" || cfg.Server.Key == "" {
+		return "tls is disabled", errSkipped
+	}
+
+	cert, err := tls.LoadX509KeyPair(
+		cfg.Server.Cert,
+		cfg.Server.Key,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		return "", err
+	}
+
+	if time.Now().After(leaf.NotAfter) {
+		return "", fmt.Errorf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	return fmt.Sprintf("valid until %s", leaf.NotAfter.Format(time.RFC3339)), nil
+}
+
+func readyUpstreams(proxy *upstream.Table) (string, error) {
+	if !proxy.Ready() {
+		return "", errors.New("no healthy upstream endpoint")
+	}
+
+	healthy := 0
+
+	for _, u := range proxy.Upstreams() {
+		healthy += u.Healthy()
+	}
+
+	return fmt.Sprintf("%d healthy endpoints", healthy), nil
+}
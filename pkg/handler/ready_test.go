@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+	"github.com/webhippie/oauth2-proxy/pkg/upstream"
+)
+
+// unreachableStore fails the ping like a disconnected backend.
+type unreachableStore struct {
+	store.SessionStore
+}
+
+func (s unreachableStore) Ping() error {
+	return errors.New("connection refused")
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers bool
+		storage   store.SessionStore
+		prepare   func(cfg *config.Config)
+		status    int
+		checks    map[string]string
+	}{
+		{
+			name:      "ready",
+			providers: true,
+			storage:   store.NewMemory(10, 0),
+			prepare: func(cfg *config.Config) {
+				cfg.Proxy.Endpoints = []string{"http://127.0.0.1:1"}
+			},
+			status: http.StatusOK,
+			checks: map[string]string{
+				"providers":     ReadyOK,
+				"session_store": ReadyOK,
+				"templates":     ReadyOK,
+				"certificates":  ReadySkipped,
+				"upstreams":     ReadyOK,
+			},
+		},
+		{
+			name:    "not ready",
+			storage: unreachableStore{},
+			prepare: func(cfg *config.Config) {
+				cfg.Server.Templates = "missing"
+				cfg.Server.Cert = "missing.crt"
+				cfg.Server.Key = "missing.key"
+				cfg.Proxy.Endpoints = []string{"http://127.0.0.1:1"}
+				cfg.Proxy.Check = config.Check{Path: "/healthz", Interval: 10 * time.Millisecond, Timeout: 100 * time.Millisecond, Unhealthy: 1}
+			},
+			status: http.StatusServiceUnavailable,
+			checks: map[string]string{
+				"providers":     ReadyFailed,
+				"session_store": ReadyFailed,
+				"templates":     ReadyFailed,
+				"certificates":  ReadyFailed,
+				"upstreams":     ReadyFailed,
+			},
+		},
+		{
+			name:      "autocert",
+			providers: true,
+			storage:   store.NewMemory(10, 0),
+			prepare: func(cfg *config.Config) {
+				cfg.Server.AutoCert = true
+			},
+			status: http.StatusOK,
+			checks: map[string]string{
+				"providers":     ReadyOK,
+				"session_store": ReadyOK,
+				"templates":     ReadyOK,
+				"certificates":  ReadySkipped,
+				"upstreams":     ReadyOK,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.Server.Templates = "../../templates"
+			tt.prepare(cfg)
+
+			provider.Clear()
+			defer provider.Clear()
+
+			if tt.providers {
+				provider.Use(provider.NewOIDC(cfg))
+			}
+
+			proxy, err := upstream.New(cfg)
+
+			if err != nil {
+				t.Fatalf("failed to create routing table: %s", err)
+			}
+
+			defer proxy.Close()
+
+			for deadline := time.Now().Add(2 * time.Second); tt.status != http.StatusOK && proxy.Ready() && time.Now().Before(deadline); {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			w := httptest.NewRecorder()
+			Ready(cfg, tt.storage, proxy).ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			result := Readiness{}
+
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("failed to decode readiness: %s", err)
+			}
+
+			for name, status := range tt.checks {
+				check := result.Checks[name]
+
+				if check.Status != status {
+					t.Errorf("expected %s to be %s, got %+v", name, status, check)
+				}
+
+				if status == ReadyFailed && check.Message == "" {
+					t.Errorf("expected a reason for the failed %s check", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/middleware/realip"
+)
+
+// redirectTarget accepts relative paths or absolute URLs pointing to the
+// host of the proxy, the hosts of the routes or the configured redirect
+// hosts, everything else falls back to the root path.
+func redirectTarget(cfg *config.Config, target string) string {
+	if !safeTarget(target) {
+		return "/"
+	}
+
+	if relativeTarget(target) {
+		return target
+	}
+
+	parsed, err := url.Parse(target)
+
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.User != nil {
+		return "/"
+	}
+
+	for _, pattern := range redirectHosts(cfg) {
+		if matchRedirect(pattern, parsed) {
+			return parsed.String()
+		}
+	}
+
+	log.Debug().
+		Str("target", target).
+		Msg("rejected redirect to unknown host")
+
+	return "/"
+}
+
+// originalTarget captures the requested URL to restore it after login, if
+// the host is not allowed as redirect only the path gets preserved. The
+// forwarded scheme is only used if the request passed a trusted proxy.
+func originalTarget(cfg *config.Config, r *http.Request) string {
+	scheme := "http"
+
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); realip.Forwarded(r) && (proto == "http" || proto == "https") {
+		scheme = proto
+	}
+
+	target := scheme + "://" + r.Host + r.URL.RequestURI()
+
+	if redirectTarget(cfg, target) == target {
+		return target
+	}
+
+	return r.URL.RequestURI()
+}
+
+// safeTarget rejects whitespace, control characters and backslashes, browsers
+// strip or normalize them which turns paths into protocol-relative URLs.
+func safeTarget(target string) bool {
+	for _, c := range target {
+		if c == '\\' || unicode.IsSpace(c) || unicode.IsControl(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func relativeTarget(target string) bool {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		return false
+	}
+
+	parsed, err := url.Parse(target)
+	return err == nil && parsed.Scheme == "" && parsed.Host == ""
+}
+
+func redirectHosts(cfg *config.Config) []string {
+	result := []string{}
+
+	if host, err := url.Parse(cfg.Server.Host); err == nil && host.Host != "" {
+		result = append(result, host.Host)
+	}
+
+	for _, route := range cfg.Proxy.Routes {
+		if route.Host != "" {
+			result = append(result, route.Host)
+		}
+	}
+
+	return append(result, cfg.Proxy.RedirectHosts...)
+}
+
+// matchRedirect compares the host including the port if the pattern defines
+// one, patterns starting with *. match any subdomain.
+func matchRedirect(pattern string, target *url.URL) bool {
+	pattern = strings.ToLower(pattern)
+
+	if _, _, err := net.SplitHostPort(pattern); err == nil {
+		return pattern == strings.ToLower(target.Host)
+	}
+
+	host := strings.ToLower(target.Hostname())
+
+	if strings.HasPrefix(pattern, "*.") {
+		return len(host) > len(pattern)-1 && strings.HasSuffix(host, pattern[1:])
+	}
+
+	return pattern == host
+}
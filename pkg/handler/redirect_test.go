@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/middleware/realip"
+)
+
+func redirectConfig() *config.Config {
+	cfg := config.New()
+	cfg.Server.Host = "https://auth.example.com"
+	cfg.Proxy.Routes = []config.Route{{Host: "*.apps.example.com"}}
+	cfg.Proxy.RedirectHosts = []string{"wiki.example.org", "local:8080"}
+
+	return cfg
+}
+
+func TestRedirectTarget(t *testing.T) {
+	cfg := redirectConfig()
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/foo?x=1", "/foo?x=1"},
+		{"/", "/"},
+		{"", "/"},
+		{"//evil.com", "/"},
+		{"/\\evil.com", "/"},
+		{"/\t/evil.com", "/"},
+		{"/\n/evil.com", "/"},
+		{"/\r/evil.com", "/"},
+		{"/ /evil.com", "/"},
+		{"/\x00/evil.com", "/"},
+		{"/foo\\bar", "/"},
+		{"/%09/evil.com", "/%09/evil.com"},
+		{"https://auth.example.com/x", "https://auth.example.com/x"},
+		{"https://a.apps.example.com/x", "https://a.apps.example.com/x"},
+		{"https://apps.example.com/x", "/"},
+		{"https://evilapps.example.com/x", "/"},
+		{"https://wiki.example.org/x", "https://wiki.example.org/x"},
+		{"http://local:8080/", "http://local:8080/"},
+		{"http://local:9090/", "/"},
+		{"https://user@wiki.example.org/", "/"},
+		{"https://evil.com\\@wiki.example.org/", "/"},
+		{"https://evil.com/", "/"},
+		{"javascript:alert(1)", "/"},
+		{"evil.com", "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if got := redirectTarget(cfg, tt.target); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOriginalTarget(t *testing.T) {
+	cfg := redirectConfig()
+
+	tests := []struct {
+		name    string
+		url     string
+		remote  string
+		proto   string
+		proxies []string
+		want    string
+	}{
+		{"route host", "http://b.apps.example.com/p?q=1", "192.0.2.1:1234", "", nil, "http://b.apps.example.com/p?q=1"},
+		{"unknown host", "http://other.com/p?q=1", "192.0.2.1:1234", "", nil, "/p?q=1"},
+		{"trusted proto", "http://b.apps.example.com/p", "10.0.0.5:1234", "https", []string{"10.0.0.0/8"}, "https://b.apps.example.com/p"},
+		{"untrusted proto", "http://b.apps.example.com/p", "192.0.2.1:1234", "https", []string{"10.0.0.0/8"}, "http://b.apps.example.com/p"},
+		{"without trusted proxies", "http://b.apps.example.com/p", "10.0.0.5:1234", "https", nil, "http://b.apps.example.com/p"},
+		{"invalid proto", "http://b.apps.example.com/p", "10.0.0.5:1234", "javascript", []string{"10.0.0.0/8"}, "http://b.apps.example.com/p"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+
+			handler := realip.Trusted(tt.proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = originalTarget(cfg, r)
+			}))
+
+			r := httptest.NewRequest("GET", tt.url, nil)
+			r.RemoteAddr = tt.remote
+
+			if tt.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
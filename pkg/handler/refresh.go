@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/audit"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/metrics"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+)
+
+// renew refreshes the access token if it expires within the configured
+// duration, the organization membership gets checked again and the updated
+// session gets stored. An error means the session is not usable anymore,
+// temporary provider failures keep the session until the token expires.
+func renew(cfg *config.Config, sessions *session.Manager, w http.ResponseWriter, r *http.Request, s *session.Session) (*session.Session, error) {
+	if cfg.Session.Refresh <= 0 || s.RefreshToken == "" || s.TokenExpiry.IsZero() {
+		return s, nil
+	}
+
+	if time.Until(s.TokenExpiry) > cfg.Session.Refresh {
+		return s, nil
+	}
+
+	token, orgs, err := provider.Refresh(s.Provider, s.RefreshToken)
+
+	if err != nil && err != provider.ErrNotMember && err != provider.ErrInvalidGrant && time.Now().Before(s.TokenExpiry) {
+		log.Warn().
+			Err(err).
+			Str("provider", s.Provider).
+			Str("username", s.Username).
+			Msg("failed to refresh session, keeping the current access token")
+
+		metrics.SessionRefreshes.WithLabelValues(s.Provider, audit.Failed).Inc()
+		return s, nil
+	}
+
+	if err != nil {
+		decision := audit.Failed
+
+		if err == provider.ErrNotMember {
+			decision = audit.Denied
+		}
+
+		log.Info().
+			Err(err).
+			Str("provider", s.Provider).
+			Str("username", s.Username).
+			Msg("failed to refresh session")
+
+		metrics.SessionRefreshes.WithLabelValues(s.Provider, decision).Inc()
+
+		audit.Record(r, audit.Event{
+			Action:   audit.Refresh,
+			Decision: decision,
+			Reason:   err.Error(),
+			Provider: s.Provider,
+			Username: s.Username,
+		})
+
+		return nil, err
+	}
+
+	updated := *s
+	updated.AccessToken = token.AccessToken
+	updated.TokenExpiry = token.Expiry
+	updated.Orgs = orgs
+	updated.ValidatedAt = time.Now().UTC()
+
+	if token.RefreshToken != "" {
+		updated.RefreshToken = token.RefreshToken
+	}
+
+	if raw, ok := token.Extra("id_token").(string); ok && raw != "" {
+		updated.IDToken = raw
+	}
+
+	if err := sessions.Save(w, r, &updated); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to store refreshed session")
+
+		return nil, err
+	}
+
+	log.Debug().
+		Str("provider", s.Provider).
+		Str("username", s.Username).
+		Time("expiry", updated.TokenExpiry).
+		Msg("refreshed access token")
+
+	metrics.SessionRefreshes.WithLabelValues(s.Provider, audit.Allowed).Inc()
+
+	audit.Record(r, audit.Event{
+		Action:   audit.Refresh,
+		Decision: audit.Allowed,
+		Reason:   "refreshed access token",
+		Provider: s.Provider,
+		Username: s.Username,
+	})
+
+	return &updated, nil
+}
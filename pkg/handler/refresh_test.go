@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+)
+
+// tokenServer fakes an OpenID Connect provider, refresh tokens starting
+// with invalid get rejected, failing ones cause a server error and revoked
+// ones lose the admins group.
+func tokenServer(calls *int32) *httptest.Server {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 srv.URL,
+				"authorization_endpoint": srv.URL + "/auth",
+				"token_endpoint":         srv.URL + "/token",
+				"userinfo_endpoint":      srv.URL + "/userinfo",
+				"jwks_uri":               srv.URL + "/jwks",
+			})
+		case "/jwks":
+			w.Write([]byte(`{"keys":[]}`))
+		case "/token":
+			atomic.AddInt32(calls, 1)
+			time.Sleep(20 * time.Millisecond)
+
+			r.ParseForm()
+			refresh := r.PostForm.Get("refresh_token")
+
+			if strings.HasPrefix(refresh, "failing") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			if r.PostForm.Get("grant_type") != "refresh_token" || strings.HasPrefix(refresh, "invalid") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "access-" + refresh,
+				"refresh_token": "rotated-" + refresh,
+				"token_type":    "Bearer",
+				"expires_in":    3600,
+			})
+		case "/userinfo":
+			groups := []string{"admins"}
+
+			if strings.Contains(r.Header.Get("Authorization"), "revoked") {
+				groups = []string{"others"}
+			}
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"sub":    "123",
+				"groups": groups,
+			})
+		}
+	}))
+
+	return srv
+}
+
+// errAny matches any error within the table tests.
+var errAny = errors.New("any error")
+
+func refreshConfig(issuer string) *config.Config {
+	cfg := config.New()
+	cfg.OIDC.Issuer = issuer
+	cfg.OIDC.Client = "client"
+	cfg.OIDC.GroupsClaim = "groups"
+	cfg.OIDC.Orgs = []string{"admins"}
+	cfg.Session.Name = "session"
+	cfg.Session.Lifetime = time.Hour
+	cfg.Session.Refresh = 5 * time.Minute
+
+	return cfg
+}
+
+func TestRenew(t *testing.T) {
+	var calls int32
+
+	srv := tokenServer(&calls)
+	defer srv.Close()
+
+	cfg := refreshConfig(srv.URL)
+	provider.Use(provider.NewOIDC(cfg))
+	defer provider.Clear()
+
+	sessions := session.New(cfg, store.NewCookie())
+
+	tests := []struct {
+		name    string
+		refresh string
+		expiry  time.Duration
+		access  string
+		request bool
+		kept    bool
+		err     error
+	}{
+		{
+			name:    "valid token",
+			refresh: "fresh",
+			expiry:  time.Hour,
+			access:  "current",
+		},
+		{
+			name:    "expiring token",
+			refresh: "expiring",
+			expiry:  time.Minute,
+			access:  "access-expiring",
+			request: true,
+		},
+		{
+			name:    "expired token",
+			refresh: "expired",
+			expiry:  -time.Minute,
+			access:  "access-expired",
+			request: true,
+		},
+		{
+			name:    "invalid grant",
+			refresh: "invalid",
+			expiry:  time.Minute,
+			request: true,
+			err:     provider.ErrInvalidGrant,
+		},
+		{
+			name:    "membership revoked",
+			refresh: "revoked",
+			expiry:  time.Minute,
+			request: true,
+			err:     provider.ErrNotMember,
+		},
+		{
+			name:    "provider failure",
+			refresh: "failing",
+			expiry:  time.Minute,
+			access:  "current",
+			request: true,
+			kept:    true,
+		},
+		{
+			name:    "provider failure after expiry",
+			refresh: "failing-expired",
+			expiry:  -time.Minute,
+			request: true,
+			err:     errAny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+
+			w := httptest.NewRecorder()
+			s, err := renew(cfg, sessions, w, httptest.NewRequest("GET", "/", nil), &session.Session{
+				Provider:     "oidc",
+				AccessToken:  "current",
+				RefreshToken: tt.refresh,
+				TokenExpiry:  time.Now().Add(tt.expiry),
+			})
+
+			if got := atomic.LoadInt32(&calls) > 0; got != tt.request {
+				t.Errorf("expected token request %v, got %v", tt.request, got)
+			}
+
+			if tt.err != nil {
+				if err == nil {
+					t.Fatalf("expected error, got session %+v", s)
+				}
+
+				if tt.err != errAny && err != tt.err {
+					t.Errorf("expected error %v, got %v", tt.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if s.AccessToken != tt.access {
+				t.Errorf("expected access token %q, got %q", tt.access, s.AccessToken)
+			}
+
+			if !tt.request || tt.kept {
+				return
+			}
+
+			if s.RefreshToken != "rotated-"+tt.refresh {
+				t.Errorf("expected rotated refresh token, got %q", s.RefreshToken)
+			}
+
+			if len(s.Orgs) != 1 || s.Orgs[0] != "admins" {
+				t.Errorf("expected admins group, got %v", s.Orgs)
+			}
+
+			if !s.TokenExpiry.After(time.Now().Add(time.Hour - time.Minute)) {
+				t.Errorf("expected renewed expiry, got %s", s.TokenExpiry)
+			}
+
+			if w.Header().Get("Set-Cookie") == "" {
+				t.Errorf("expected refreshed session cookie")
+			}
+		})
+	}
+}
+
+func TestRenewConcurrent(t *testing.T) {
+	var calls int32
+
+	srv := tokenServer(&calls)
+	defer srv.Close()
+
+	cfg := refreshConfig(srv.URL)
+	provider.Use(provider.NewOIDC(cfg))
+	defer provider.Clear()
+
+	sessions := session.New(cfg, store.NewCookie())
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s, err := renew(cfg, sessions, httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), &session.Session{
+				Provider:     "oidc",
+				RefreshToken: "concurrent",
+				TokenExpiry:  time.Now().Add(-time.Minute),
+			})
+
+			if err != nil || s.AccessToken != "access-concurrent" {
+				t.Errorf("expected refreshed session, got %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected a single token request, got %d", got)
+	}
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/upstream"
+)
+
+var (
+	// errEncodedSlash gets returned if the original URL contains an encoded
+	// slash, upstreams interpret them differently.
+	errEncodedSlash = errors.New("encoded slash in original url")
+)
+
+// AuthRequest answers subrequests from nginx auth_request or Traefik
+// forwardAuth, the identity gets returned as response headers. The policy of
+// the route matching the original URL gets evaluated, without a matching
+// route the global policy applies.
+func AuthRequest(cfg *config.Config, sessions *session.Manager, signer *jwt.Signer, proxy *upstream.Table) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := bearerToken(cfg, r); token != "" {
+			s, status := bearerSession(cfg, r, token)
+
+			if s == nil {
+				rejectBearer(w, status)
+				return
+			}
+
+			if permitted(cfg, proxy, w, r, s) {
+				accept(cfg, signer, w, s)
+			}
+
+			return
+		}
+
+		s, err := sessions.Load(r)
+
+		if err == nil {
+			s, err = renew(cfg, sessions, w, r, s)
+		}
+
+		if err == nil {
+			s, err = revalidate(cfg, sessions, w, r, s)
+		}
+
+		if err != nil {
+			if err != session.ErrMissing {
+				log.Debug().
+					Err(err).
+					Msg("failed to load session")
+			}
+
+			w.Header().Set(
+				"Location",
+				path.Join(
+					cfg.Server.Root,
+					"login",
+				)+"?redirect="+url.QueryEscape(originalURL(r)),
+			)
+
+			plain(w, http.StatusUnauthorized)
+			return
+		}
+
+		if permitted(cfg, proxy, w, r, s) {
+			accept(cfg, signer, w, s)
+		}
+	}
+}
+
+// permitted evaluates the policy for the original request, denied requests
+// get answered with a forbidden status.
+func permitted(cfg *config.Config, proxy *upstream.Table, w http.ResponseWriter, r *http.Request, s *session.Session) bool {
+	original, err := originalRequest(r)
+
+	if err != nil {
+		log.Info().
+			Err(err).
+			Str("provider", s.Provider).
+			Str("username", s.Username).
+			Msg("denied access for invalid original url")
+
+		plain(w, http.StatusForbidden)
+		return false
+	}
+
+	route, rules := "default", policy.New(cfg.Proxy.Policy)
+
+	if u := proxy.Match(original); u != nil {
+		route, rules = u.Route.Name, u.Policy
+	}
+
+	if rule, err := rules.Evaluate(s); err != nil {
+		denied(r, route, rule, err, s)
+		plain(w, http.StatusForbidden)
+
+		return false
+	}
+
+	return true
+}
+
+func accept(cfg *config.Config, signer *jwt.Signer, w http.ResponseWriter, s *session.Session) {
+	for name, values := range identityHeaders(cfg, s) {
+		w.Header()[name] = values
+	}
+
+	if signer != nil && cfg.JWT.Header != "" {
+		assertion, err := signAssertion(cfg, signer, s)
+
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("failed to sign assertion")
+
+			plain(w, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set(cfg.JWT.Header, assertion)
+	}
+
+	plain(w, http.StatusAccepted)
+}
+
+// plain writes the status text as plain response.
+func plain(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(status)
+
+	io.WriteString(w, http.StatusText(status))
+}
+
+// originalRequest builds the request originally received by the proxy in
+// front with a clean path, it's only used to select the route.
+func originalRequest(r *http.Request) (*http.Request, error) {
+	target, err := url.Parse(originalURL(r))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(strings.ToLower(target.RawPath), "%2f") {
+		return nil, errEncodedSlash
+	}
+
+	host := target.Host
+
+	if host == "" {
+		host = r.Header.Get("X-Forwarded-Host")
+	}
+
+	if host == "" {
+		host = r.Host
+	}
+
+	return &http.Request{
+		Host: host,
+		URL: &url.URL{
+			Path: policy.CleanPath(target.Path),
+		},
+		Header: http.Header{},
+	}, nil
+}
+
+// originalURL detects the URL originally requested from the proxy in front.
+func originalURL(r *http.Request) string {
+	if original := r.Header.Get("X-Original-URL"); original != "" {
+		return original
+	}
+
+	uri := r.Header.Get("X-Forwarded-Uri")
+
+	if uri == "" {
+		return "/"
+	}
+
+	host := r.Header.Get("X-Forwarded-Host")
+
+	if host == "" {
+		return uri
+	}
+
+	proto := r.Header.Get("X-Forwarded-Proto")
+
+	if proto == "" {
+		proto = "https"
+	}
+
+	return proto + "://" + host + uri
+}
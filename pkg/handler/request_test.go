@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+	"github.com/webhippie/oauth2-proxy/pkg/upstream"
+)
+
+func TestAuthRequestPolicy(t *testing.T) {
+	cfg := config.New()
+	cfg.Session.Secret = "secret"
+	cfg.Session.Name = "_session"
+	cfg.Session.Lifetime = time.Hour
+	cfg.Proxy.Policy = config.Policy{Allow: config.Rules{Users: []string{"github:alice", "github:bob"}}}
+	cfg.Proxy.Routes = []config.Route{
+		{
+			Name:      "admin",
+			Host:      "app.example.com",
+			Path:      "/admin",
+			Endpoints: []string{"http://127.0.0.1:1"},
+			Policy:    config.Policy{Allow: config.Rules{Users: []string{"github:alice"}}},
+		},
+	}
+
+	proxy, err := upstream.New(cfg)
+
+	if err != nil {
+		t.Fatalf("failed to create routing table: %s", err)
+	}
+
+	defer proxy.Close()
+
+	sessions := session.New(cfg, store.NewCookie())
+	handler := AuthRequest(cfg, sessions, nil, proxy)
+
+	tests := []struct {
+		name     string
+		username string
+		original string
+		status   int
+	}{
+		{"anonymous", "", "https://app.example.com/admin", http.StatusUnauthorized},
+		{"route allowed", "alice", "https://app.example.com/admin/users", http.StatusAccepted},
+		{"route denied", "bob", "https://app.example.com/admin/users", http.StatusForbidden},
+		{"dot segments", "bob", "https://app.example.com/public/../admin/users", http.StatusForbidden},
+		{"duplicate slashes", "bob", "https://app.example.com//admin", http.StatusForbidden},
+		{"encoded slash", "bob", "https://app.example.com/public/..%2Fadmin", http.StatusForbidden},
+		{"global allowed", "bob", "https://app.example.com/public", http.StatusAccepted},
+		{"global denied", "carol", "https://app.example.com/public", http.StatusForbidden},
+		{"other host", "bob", "https://other.example.com/admin", http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/auth", nil)
+			r.Header.Set("X-Original-URL", tt.original)
+
+			if tt.username != "" {
+				w := httptest.NewRecorder()
+
+				if err := sessions.Save(w, httptest.NewRequest("GET", "/", nil), &session.Session{Provider: "github", Username: tt.username}); err != nil {
+					t.Fatalf("failed to save session: %s", err)
+				}
+
+				for _, cookie := range w.Result().Cookies() {
+					r.AddCookie(cookie)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
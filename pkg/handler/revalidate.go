@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/audit"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/metrics"
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+)
+
+// revalidate checks the organization membership again once the configured
+// interval has passed. Sessions of users who are not allowed anymore get
+// rejected, provider failures keep the session until the next attempt.
+func revalidate(cfg *config.Config, sessions *session.Manager, w http.ResponseWriter, r *http.Request, s *session.Session) (*session.Session, error) {
+	if cfg.Session.Revalidate <= 0 || s.AccessToken == "" {
+		return s, nil
+	}
+
+	validated := s.ValidatedAt
+
+	if validated.IsZero() {
+		validated = s.CreatedAt
+	}
+
+	if time.Since(validated) < cfg.Session.Revalidate {
+		return s, nil
+	}
+
+	orgs, err := provider.Revalidate(s.Provider, s.AccessToken, cfg.Session.Revalidate)
+
+	if err == provider.ErrNotMember {
+		log.Info().
+			Str("provider", s.Provider).
+			Str("username", s.Username).
+			Strs("orgs", orgs).
+			Msg("session revoked, user is not a member anymore")
+
+		metrics.SessionRevalidations.WithLabelValues(s.Provider, audit.Denied).Inc()
+
+		audit.Record(r, audit.Event{
+			Action:   audit.Revalidate,
+			Decision: audit.Denied,
+			Reason:   err.Error(),
+			Provider: s.Provider,
+			Username: s.Username,
+		})
+
+		return nil, err
+	}
+
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("provider", s.Provider).
+			Str("username", s.Username).
+			Msg("failed to revalidate session")
+
+		metrics.SessionRevalidations.WithLabelValues(s.Provider, audit.Failed).Inc()
+		return s, nil
+	}
+
+	updated := *s
+	updated.Orgs = orgs
+	updated.ValidatedAt = time.Now().UTC()
+
+	if err := sessions.Save(w, r, &updated); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("failed to store revalidated session")
+
+		return nil, err
+	}
+
+	log.Debug().
+		Str("provider", s.Provider).
+		Str("username", s.Username).
+		Msg("revalidated session")
+
+	metrics.SessionRevalidations.WithLabelValues(s.Provider, audit.Allowed).Inc()
+	return &updated, nil
+}
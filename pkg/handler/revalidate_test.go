@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/provider"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+)
+
+// userinfoServer fakes the userinfo endpoint, tokens starting with revoked
+// lose the admins group and failing ones cause a server error.
+func userinfoServer(calls *int32) *httptest.Server {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":            srv.URL,
+				"userinfo_endpoint": srv.URL + "/userinfo",
+				"jwks_uri":          srv.URL + "/jwks",
+			})
+		case "/userinfo":
+			atomic.AddInt32(calls, 1)
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			if strings.HasPrefix(token, "failing") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			groups := []string{"admins", "devs"}
+
+			if strings.HasPrefix(token, "revoked") {
+				groups = []string{"devs"}
+			}
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"sub":    "123",
+				"groups": groups,
+			})
+		}
+	}))
+
+	return srv
+}
+
+func TestRevalidate(t *testing.T) {
+	var calls int32
+
+	srv := userinfoServer(&calls)
+	defer srv.Close()
+
+	ttl := 200 * time.Millisecond
+
+	cfg := refreshConfig(srv.URL)
+	cfg.Session.Revalidate = ttl
+	provider.Use(provider.NewOIDC(cfg))
+	defer provider.Clear()
+
+	sessions := session.New(cfg, store.NewCookie())
+
+	steps := []struct {
+		name    string
+		token   string
+		age     time.Duration
+		wait    time.Duration
+		calls   int32
+		updated bool
+		err     error
+	}{
+		{name: "within ttl", token: "current", age: ttl / 2},
+		{name: "ttl passed", token: "current", age: 2 * ttl, calls: 1, updated: true},
+		{name: "cached for the same token", token: "current", age: 2 * ttl, updated: true},
+		{name: "cache expired", token: "current", age: 2 * ttl, wait: ttl, calls: 1, updated: true},
+		{name: "membership revoked", token: "revoked", age: 2 * ttl, calls: 1, err: provider.ErrNotMember},
+		{name: "provider failure keeps session", token: "failing", age: 2 * ttl, calls: 1},
+		{name: "provider failure cached", token: "failing", age: 2 * ttl, wait: ttl},
+		{name: "without access token", token: "", age: 2 * ttl},
+	}
+
+	for _, step := range steps {
+		time.Sleep(step.wait)
+		before := atomic.LoadInt32(&calls)
+
+		s := &session.Session{
+			Provider:    "oidc",
+			Username:    "jdoe",
+			AccessToken: step.token,
+			Orgs:        []string{"admins"},
+			CreatedAt:   time.Now().Add(-time.Hour),
+			ValidatedAt: time.Now().Add(-step.age),
+		}
+
+		w := httptest.NewRecorder()
+		got, err := revalidate(cfg, sessions, w, httptest.NewRequest("GET", "/", nil), s)
+
+		if err != step.err {
+			t.Fatalf("%s: expected %v, got %v", step.name, step.err, err)
+		}
+
+		if made := atomic.LoadInt32(&calls) - before; made != step.calls {
+			t.Errorf("%s: expected %d provider calls, got %d", step.name, step.calls, made)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if !step.updated {
+			if got != s || len(w.Result().Cookies()) != 0 {
+				t.Errorf("%s: expected session to be kept as is", step.name)
+			}
+
+			continue
+		}
+
+		if time.Since(got.ValidatedAt) > time.Second || len(got.Orgs) != 2 || len(w.Result().Cookies()) == 0 {
+			t.Errorf("%s: expected revalidated session to be stored, got %+v", step.name, got)
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := refreshConfig(srv.URL)
+		s := &session.Session{Provider: "oidc", AccessToken: "revoked-disabled", CreatedAt: time.Now().Add(-time.Hour)}
+
+		if got, err := revalidate(cfg, sessions, httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), s); err != nil || got != s {
+			t.Errorf("expected session to be kept, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownKey gets returned if no matching key could be found.
+	ErrUnknownKey = errors.New("no matching key found")
+)
+
+const (
+	// keySetMaxAge defines how long fetched keys get cached at most.
+	keySetMaxAge = 24 * time.Hour
+)
+
+// JWK defines a single JSON web key.
+type JWK struct {
+	KeyID     string `json:"kid,omitempty"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS defines a set of JSON web keys.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKey converts the JSON web key into a public key.
+func (k JWK) PublicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Curve)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.KeyType)
+}
+
+// KeySet fetches and caches remote JSON web keys, keys get refetched if an
+// unknown key id shows up to support key rotation.
+type KeySet struct {
+	URL     string
+	Client  *http.Client
+	Refresh time.Duration
+
+	mutex   sync.Mutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// NewKeySet prepares a new remote key set.
+func NewKeySet(url string, client *http.Client) *KeySet {
+	return &KeySet{
+		URL:     url,
+		Client:  client,
+		Refresh: time.Minute,
+	}
+}
+
+// Verify parses the token and verifies the signature with a matching key.
+func (s *KeySet) Verify(raw string) (*Token, error) {
+	t, err := Parse(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.lookup(t.Header.KeyID, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		if keys, err = s.lookup(t.Header.KeyID, true); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range keys {
+		if err := t.Verify(key); err == nil {
+			return t, nil
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+
+	return nil, ErrSignature
+}
+
+func (s *KeySet) lookup(kid string, force bool) ([]interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.keys == nil || time.Since(s.fetched) > keySetMaxAge || (force && time.Since(s.fetched) > s.Refresh) {
+		if err := s.fetch(); err != nil {
+			return nil, err
+		}
+	}
+
+	if kid != "" {
+		if key, ok := s.keys[kid]; ok {
+			return []interface{}{key}, nil
+		}
+
+		return nil, nil
+	}
+
+	result := make([]interface{}, 0, len(s.keys))
+
+	for _, key := range s.keys {
+		result = append(result, key)
+	}
+
+	return result, nil
+}
+
+func (s *KeySet) fetch() error {
+	resp, err := s.Client.Get(s.URL)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d fetching keys", resp.StatusCode)
+	}
+
+	set := JWKS{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.PublicKey()
+
+		if err != nil {
+			continue
+		}
+
+		kid := jwk.KeyID
+
+		if kid == "" {
+			kid = fmt.Sprintf("#%d", i)
+		}
+
+		keys[kid] = key
+	}
+
+	s.keys = keys
+	s.fetched = time.Now()
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMalformed gets returned if the token can't be parsed.
+	ErrMalformed = errors.New("token is malformed")
+
+	// ErrSignature gets returned if the token signature is invalid.
+	ErrSignature = errors.New("token signature is invalid")
+
+	// ErrAlgorithm gets returned if the token algorithm is not supported.
+	ErrAlgorithm = errors.New("token algorithm is not supported")
+
+	// ErrExpired gets returned if the token is expired.
+	ErrExpired = errors.New("token is expired")
+)
+
+// Header defines the header of a token.
+type Header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Claims defines the claims of a token.
+type Claims map[string]interface{}
+
+// String returns a string claim or an empty string.
+func (c Claims) String(name string) string {
+	if val, ok := c[name].(string); ok {
+		return val
+	}
+
+	return ""
+}
+
+// Strings returns a claim as list of strings, single strings are wrapped.
+func (c Claims) Strings(name string) []string {
+	switch val := c[name].(type) {
+	case string:
+		return []string{val}
+	case []string:
+		return val
+	case []interface{}:
+		result := make([]string, 0, len(val))
+
+		for _, raw := range val {
+			if str, ok := raw.(string); ok {
+				result = append(result, str)
+			}
+		}
+
+		return result
+	}
+
+	return nil
+}
+
+// Time returns a numeric date claim as time.
+func (c Claims) Time(name string) time.Time {
+	switch val := c[name].(type) {
+	case float64:
+		return time.Unix(int64(val), 0)
+	case int64:
+		return time.Unix(val, 0)
+	case json.Number:
+		if num, err := val.Int64(); err == nil {
+			return time.Unix(num, 0)
+		}
+	}
+
+	return time.Time{}
+}
+
+// Token defines a parsed token.
+type Token struct {
+	Header    Header
+	Claims    Claims
+	signed    string
+	signature []byte
+}
+
+// Parse parses a compact serialized token without verifying it.
+func Parse(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	t := &Token{
+		Claims: Claims{},
+		signed: parts[0] + "." + parts[1],
+	}
+
+	if err := decodeSegment(parts[0], &t.Header); err != nil {
+		return nil, ErrMalformed
+	}
+
+	if err := decodeSegment(parts[1], &t.Claims); err != nil {
+		return nil, ErrMalformed
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	t.signature = signature
+	return t, nil
+}
+
+// Verify checks the token signature with the given key.
+func (t *Token) Verify(key interface{}) error {
+	hash, ok := hashes[t.Header.Algorithm]
+
+	if !ok {
+		return ErrAlgorithm
+	}
+
+	switch t.Header.Algorithm[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+
+		if !ok {
+			return ErrAlgorithm
+		}
+
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(t.signed))
+
+		if !hmac.Equal(mac.Sum(nil), t.signature) {
+			return ErrSignature
+		}
+
+		return nil
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+
+		if !ok {
+			return ErrAlgorithm
+		}
+
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest(hash, t.signed), t.signature); err != nil {
+			return ErrSignature
+		}
+
+		return nil
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+
+		if !ok || pub.Curve != curves[t.Header.Algorithm] {
+			return ErrAlgorithm
+		}
+
+		size := (pub.Curve.Params().BitSize + 7) / 8
+
+		if len(t.signature) != 2*size {
+			return ErrSignature
+		}
+
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+
+		if !ecdsa.Verify(pub, digest(hash, t.signed), r, s) {
+			return ErrSignature
+		}
+
+		return nil
+	}
+
+	return ErrAlgorithm
+}
+
+// Validate checks the time based claims with the given leeway.
+func (t *Token) Validate(leeway time.Duration) error {
+	now := time.Now()
+
+	if exp := t.Claims.Time("exp"); !exp.IsZero() && now.After(exp.Add(leeway)) {
+		return ErrExpired
+	}
+
+	if nbf := t.Claims.Time("nbf"); !nbf.IsZero() && now.Add(leeway).Before(nbf) {
+		return fmt.Errorf("token is not valid before %s", nbf)
+	}
+
+	return nil
+}
+
+var hashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256,
+	"HS384": crypto.SHA384,
+	"HS512": crypto.SHA512,
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+var curves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+func digest(hash crypto.Hash, input string) []byte {
+	h := hash.New()
+	h.Write([]byte(input))
+
+	return h.Sum(nil)
+}
+
+func decodeSegment(segment string, val interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, val)
+}
@@ -0,0 +1,244 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signToken builds a compact token, the key type selects the signature.
+func signToken(t *testing.T, header Header, claims Claims, key interface{}) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	signature := []byte{}
+
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(hashes[header.Algorithm].New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, hashes[header.Algorithm], digest(hashes[header.Algorithm], signed))
+
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+
+		signature = sig
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyAlgorithm(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	if err != nil {
+		t.Fatalf("failed to marshal key: %s", err)
+	}
+
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	claims := Claims{"sub": "jdoe"}
+
+	tests := []struct {
+		name   string
+		header Header
+		sign   interface{}
+		verify interface{}
+		err    error
+	}{
+		{"rsa", Header{Algorithm: "RS256"}, key, &key.PublicKey, nil},
+		{"rsa with other key", Header{Algorithm: "RS256"}, other, &key.PublicKey, ErrSignature},
+		{"hmac", Header{Algorithm: "HS256"}, []byte("secret"), []byte("secret"), nil},
+		{"hmac with other secret", Header{Algorithm: "HS256"}, []byte("secret"), []byte("other"), ErrSignature},
+		{"hmac with public pem against rsa key", Header{Algorithm: "HS256"}, public, &key.PublicKey, ErrAlgorithm},
+		{"hmac with public der against rsa key", Header{Algorithm: "HS256"}, der, &key.PublicKey, ErrAlgorithm},
+		{"rsa against secret", Header{Algorithm: "RS256"}, key, []byte("secret"), ErrAlgorithm},
+		{"ecdsa against rsa key", Header{Algorithm: "ES256"}, nil, &key.PublicKey, ErrAlgorithm},
+		{"none", Header{Algorithm: "none"}, nil, &key.PublicKey, ErrAlgorithm},
+		{"none uppercase", Header{Algorithm: "NONE"}, nil, &key.PublicKey, ErrAlgorithm},
+		{"none against secret", Header{Algorithm: "none"}, nil, []byte(""), ErrAlgorithm},
+		{"empty", Header{}, nil, &key.PublicKey, ErrAlgorithm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Parse(signToken(t, tt.header, claims, tt.sign))
+
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+
+			if err := token.Verify(tt.verify); err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("tampered claims", func(t *testing.T) {
+		parts := strings.Split(signToken(t, Header{Algorithm: "RS256"}, claims, key), ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`))
+
+		token, err := Parse(strings.Join(parts, "."))
+
+		if err != nil {
+			t.Fatalf("failed to parse: %s", err)
+		}
+
+		if err := token.Verify(&key.PublicKey); err != ErrSignature {
+			t.Errorf("expected %v, got %v", ErrSignature, err)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims Claims
+		valid  bool
+		err    error
+	}{
+		{"without time claims", Claims{}, true, nil},
+		{"valid", Claims{"exp": float64(now.Add(time.Hour).Unix()), "nbf": float64(now.Add(-time.Hour).Unix())}, true, nil},
+		{"expired", Claims{"exp": float64(now.Add(-time.Hour).Unix())}, false, ErrExpired},
+		{"expired within leeway", Claims{"exp": float64(now.Add(-30 * time.Second).Unix())}, true, nil},
+		{"not yet valid", Claims{"nbf": float64(now.Add(time.Hour).Unix())}, false, nil},
+		{"not yet valid within leeway", Claims{"nbf": float64(now.Add(30 * time.Second).Unix())}, true, nil},
+		{"numeric expiry", Claims{"exp": json.Number(fmt.Sprint(now.Add(-time.Hour).Unix()))}, false, ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &Token{Claims: tt.claims}
+			err := token.Validate(time.Minute)
+
+			if tt.valid != (err == nil) {
+				t.Fatalf("expected valid=%v, got %v", tt.valid, err)
+			}
+
+			if tt.err != nil && err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	second, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	var (
+		hits  int32
+		mutex sync.Mutex
+		set   = JWKS{Keys: []JWK{rsaJWK("first", &first.PublicKey)}}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		json.NewEncoder(w).Encode(set)
+	}))
+
+	defer srv.Close()
+
+	keys := NewKeySet(srv.URL, srv.Client())
+	keys.Refresh = 0
+
+	if _, err := keys.Verify(signToken(t, Header{Algorithm: "RS256", KeyID: "first"}, Claims{}, first)); err != nil {
+		t.Fatalf("expected valid token, got %s", err)
+	}
+
+	mutex.Lock()
+	set.Keys = append(set.Keys, JWK{
+		KeyID:   "second",
+		KeyType: "EC",
+		Curve:   "P-256",
+		X:       base64.RawURLEncoding.EncodeToString(second.X.Bytes()),
+		Y:       base64.RawURLEncoding.EncodeToString(second.Y.Bytes()),
+	})
+	mutex.Unlock()
+
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"second"}`)) +
+		"." + base64.RawURLEncoding.EncodeToString([]byte(`{}`))
+
+	r, s, err := ecdsa.Sign(rand.Reader, second, digest(crypto.SHA256, signed))
+
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+
+	signature := make([]byte, 64)
+	copy(signature[32-len(r.Bytes()):32], r.Bytes())
+	copy(signature[64-len(s.Bytes()):], s.Bytes())
+
+	if _, err := keys.Verify(signed + "." + base64.RawURLEncoding.EncodeToString(signature)); err != nil {
+		t.Fatalf("expected rotated key to be fetched, got %s", err)
+	}
+
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("expected two fetches, got %d", got)
+	}
+
+	keys.Refresh = time.Hour
+
+	if _, err := keys.Verify(signToken(t, Header{Algorithm: "RS256", KeyID: "unknown"}, Claims{}, first)); err != ErrUnknownKey {
+		t.Errorf("expected %v, got %v", ErrUnknownKey, err)
+	}
+
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("expected unknown keys to respect the refresh interval, got %d fetches", got)
+	}
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		KeyID:   kid,
+		KeyType: "RSA",
+		N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
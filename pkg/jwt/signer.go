@@ -0,0 +1,297 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Signer signs tokens with a private key and publishes the public keys.
+type Signer struct {
+	Algorithm string
+	KeyID     string
+	key       interface{}
+	keys      JWKS
+}
+
+// LoadSigner loads the signing key from the directory, additional PEM
+// encoded keys within the directory get published as well to support key
+// rotation. Missing RSA or ECDSA signing keys get generated.
+func LoadSigner(dir, name, algorithm string) (*Signer, error) {
+	if _, ok := hashes[algorithm]; !ok {
+		return nil, ErrAlgorithm
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	file := path.Join(dir, name)
+
+	s := &Signer{
+		Algorithm: algorithm,
+		KeyID:     strings.TrimSuffix(name, filepath.Ext(name)),
+		keys: JWKS{
+			Keys: []JWK{},
+		},
+	}
+
+	if strings.HasPrefix(algorithm, "HS") {
+		secret, err := ioutil.ReadFile(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s.key = []byte(strings.TrimSpace(string(secret)))
+		return s, nil
+	}
+
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		log.Info().
+			Str("file", file).
+			Msg("generating new signing key")
+
+		if err := generateKey(file, algorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := readKey(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if algorithm[:2] != "RS" {
+			return nil, fmt.Errorf("key %s doesn't match algorithm %s", name, algorithm)
+		}
+	case *ecdsa.PrivateKey:
+		if k.Curve != curves[algorithm] {
+			return nil, fmt.Errorf("key %s doesn't match algorithm %s", name, algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type in %s", name)
+	}
+
+	s.key = key
+
+	files, err := filepath.Glob(path.Join(dir, "*.pem"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(name, ".pem") {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	for _, f := range files {
+		other, err := readKey(f)
+
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("file", f).
+				Msg("failed to read published key")
+
+			continue
+		}
+
+		base := filepath.Base(f)
+		jwk, err := publicJWK(other, strings.TrimSuffix(base, filepath.Ext(base)))
+
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("file", f).
+				Msg("failed to convert published key")
+
+			continue
+		}
+
+		if jwk.KeyID == s.KeyID {
+			jwk.Algorithm = algorithm
+		}
+
+		s.keys.Keys = append(s.keys.Keys, jwk)
+	}
+
+	return s, nil
+}
+
+// Sign creates a signed compact serialized token for the claims.
+func (s *Signer) Sign(claims Claims) (string, error) {
+	header, err := json.Marshal(Header{
+		Algorithm: s.Algorithm,
+		Type:      "JWT",
+		KeyID:     s.KeyID,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := hashes[s.Algorithm]
+
+	var signature []byte
+
+	switch key := s.key.(type) {
+	case []byte:
+		mac := hmac.New(hash.New, key)
+		mac.Write([]byte(signed))
+
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest(hash, signed)); err != nil {
+			return "", err
+		}
+	case *ecdsa.PrivateKey:
+		r, sig, err := ecdsa.Sign(rand.Reader, key, digest(hash, signed))
+
+		if err != nil {
+			return "", err
+		}
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+
+		r.FillBytes(signature[:size])
+		sig.FillBytes(signature[size:])
+	default:
+		return "", ErrAlgorithm
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// JWKS returns the public keys, symmetric keys are never published.
+func (s *Signer) JWKS() JWKS {
+	return s.keys
+}
+
+func readKey(file string) (crypto.Signer, error) {
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+
+	if block == nil {
+		return nil, errors.New("no pem encoded key found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported pem block %s", block.Type)
+}
+
+func generateKey(file, algorithm string) error {
+	var block *pem.Block
+
+	switch algorithm[:2] {
+	case "RS":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+		if err != nil {
+			return err
+		}
+
+		block = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}
+	case "ES":
+		key, err := ecdsa.GenerateKey(curves[algorithm], rand.Reader)
+
+		if err != nil {
+			return err
+		}
+
+		der, err := x509.MarshalECPrivateKey(key)
+
+		if err != nil {
+			return err
+		}
+
+		block = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+	default:
+		return ErrAlgorithm
+	}
+
+	return ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600)
+}
+
+func publicJWK(key crypto.Signer, kid string) (JWK, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyID:   kid,
+			KeyType: "RSA",
+			Use:     "sig",
+			N:       base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+
+		return JWK{
+			KeyID:   kid,
+			KeyType: "EC",
+			Use:     "sig",
+			Curve:   pub.Curve.Params().Name,
+			X:       base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y:       base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}, nil
+	}
+
+	return JWK{}, errors.New("unsupported public key")
+}
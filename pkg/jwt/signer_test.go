@@ -0,0 +1,116 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLoadSignerCurve(t *testing.T) {
+	tests := []struct {
+		generate string
+		load     string
+		valid    bool
+	}{
+		{"ES256", "ES256", true},
+		{"ES384", "ES384", true},
+		{"ES512", "ES512", true},
+		{"ES256", "ES384", false},
+		{"ES384", "ES512", false},
+		{"ES512", "ES256", false},
+		{"ES256", "RS256", false},
+		{"RS256", "ES256", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.generate+"/"+tt.load, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "signer")
+
+			if err != nil {
+				t.Fatalf("failed to create dir: %s", err)
+			}
+
+			defer os.RemoveAll(dir)
+
+			if _, err := LoadSigner(dir, "signing.pem", tt.generate); err != nil {
+				t.Fatalf("failed to generate key: %s", err)
+			}
+
+			s, err := LoadSigner(dir, "signing.pem", tt.load)
+
+			if tt.valid != (err == nil) {
+				t.Fatalf("expected valid=%v, got %v", tt.valid, err)
+			}
+
+			if !tt.valid {
+				return
+			}
+
+			raw, err := s.Sign(Claims{"sub": "jdoe"})
+
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+
+			token, err := Parse(raw)
+
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+
+			if err := token.Verify(&s.key.(*ecdsa.PrivateKey).PublicKey); err != nil {
+				t.Errorf("expected valid signature, got %s", err)
+			}
+		})
+	}
+}
+
+func TestVerifyCurve(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	tests := []struct {
+		algorithm string
+		hash      crypto.Hash
+		err       error
+	}{
+		{"ES256", crypto.SHA256, nil},
+		{"ES384", crypto.SHA384, ErrAlgorithm},
+		{"ES512", crypto.SHA512, ErrAlgorithm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"`+tt.algorithm+`"}`)) +
+				"." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"jdoe"}`))
+
+			r, s, err := ecdsa.Sign(rand.Reader, key, digest(tt.hash, signed))
+
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+
+			signature := make([]byte, 64)
+			copy(signature[32-len(r.Bytes()):32], r.Bytes())
+			copy(signature[64-len(s.Bytes()):], s.Bytes())
+
+			token, err := Parse(signed + "." + base64.RawURLEncoding.EncodeToString(signature))
+
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+
+			if err := token.Verify(&key.PublicKey); err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// namespace defines the prefix of all metrics.
+	namespace = "oauth2_proxy"
+)
+
+var (
+	// LoginAttempts counts the started login flows per provider.
+	LoginAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "login",
+			Name:      "attempts_total",
+			Help:      "Number of started login flows.",
+		},
+		[]string{"provider"},
+	)
+
+	// LoginSuccesses counts the completed login flows per provider.
+	LoginSuccesses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "login",
+			Name:      "successes_total",
+			Help:      "Number of successfully completed login flows.",
+		},
+		[]string{"provider"},
+	)
+
+	// LoginFailures counts the failed login flows per provider and reason.
+	LoginFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "login",
+			Name:      "failures_total",
+			Help:      "Number of failed login flows.",
+		},
+		[]string{"provider", "reason"},
+	)
+
+	// OrgDenials counts the logins denied by the organization check.
+	OrgDenials = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "login",
+			Name:      "org_denials_total",
+			Help:      "Number of logins denied as the user is not a member of an allowed organization.",
+		},
+		[]string{"provider"},
+	)
+
+	// SessionsCreated counts the created sessions.
+	SessionsCreated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "session",
+			Name:      "created_total",
+			Help:      "Number of created sessions.",
+		},
+	)
+
+	// SessionsExpired counts the requests with an expired session.
+	SessionsExpired = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "session",
+			Name:      "expired_total",
+			Help:      "Number of requests presenting an expired session.",
+		},
+	)
+
+	// SessionRefreshes counts the token refreshes per provider and result.
+	SessionRefreshes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "session",
+			Name:      "refreshes_total",
+			Help:      "Number of access token refreshes.",
+		},
+		[]string{"provider", "result"},
+	)
+
+	// SessionRevalidations counts the membership checks per provider and result.
+	SessionRevalidations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "session",
+			Name:      "revalidations_total",
+			Help:      "Number of organization membership checks for existing sessions.",
+		},
+		[]string{"provider", "result"},
+	)
+
+	// UpstreamRequests counts the upstream requests per endpoint and status.
+	UpstreamRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "upstream",
+			Name:      "requests_total",
+			Help:      "Number of requests forwarded to upstream endpoints.",
+		},
+		[]string{"route", "endpoint", "code"},
+	)
+
+	// UpstreamDuration observes the upstream latency per endpoint.
+	UpstreamDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "upstream",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of requests forwarded to upstream endpoints.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "endpoint"},
+	)
+
+	// UpstreamRetries counts the retries of the request buffer per route.
+	UpstreamRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "upstream",
+			Name:      "retries_total",
+			Help:      "Number of requests retried after a network error.",
+		},
+		[]string{"route"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		LoginAttempts,
+		LoginSuccesses,
+		LoginFailures,
+		OrgDenials,
+		SessionsCreated,
+		SessionsExpired,
+		SessionRefreshes,
+		SessionRevalidations,
+		UpstreamRequests,
+		UpstreamDuration,
+		UpstreamRetries,
+	)
+}
+
+// Sessions registers a gauge for the active sessions, count gets called on
+// every scrape.
+func Sessions(count func() (int, error)) error {
+	return prometheus.Register(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "session",
+			Name:      "active",
+			Help:      "Number of active sessions within the session store.",
+		},
+		func() float64 {
+			val, err := count()
+
+			if err != nil {
+				log.Warn().
+					Err(err).
+					Msg("failed to count active sessions")
+
+				return math.NaN()
+			}
+
+			return float64(val)
+		},
+	))
+}
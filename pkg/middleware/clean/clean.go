@@ -0,0 +1,28 @@
+package clean
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+)
+
+// Path resolves dot segments and duplicate slashes once before routing, so
+// skip rules, route selection, policies and the upstream see the same path.
+// Encoded slashes get rejected as upstreams interpret them differently.
+func Path(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(strings.ToLower(r.URL.RawPath), "%2f") {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		if clean := policy.CleanPath(r.URL.Path); clean != r.URL.Path {
+			r.URL.Path = clean
+			r.URL.RawPath = ""
+			r.RequestURI = r.URL.RequestURI()
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,45 @@
+package clean
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		target string
+		status int
+		path   string
+		uri    string
+	}{
+		{"/app/index.html", http.StatusOK, "/app/index.html", "/app/index.html"},
+		{"/health/../admin", http.StatusOK, "/admin", "/admin"},
+		{"/app/%2e%2e/admin?x=1", http.StatusOK, "/admin", "/admin?x=1"},
+		{"//app///dir/", http.StatusOK, "/app/dir/", "/app/dir/"},
+		{"/app/a%20b", http.StatusOK, "/app/a b", "/app/a%20b"},
+		{"/app/..%2fadmin", http.StatusBadRequest, "", ""},
+		{"/app%2F..%2Fadmin", http.StatusBadRequest, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			path, uri := "", ""
+
+			handler := Path(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, uri = r.URL.Path, r.RequestURI
+			}))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tt.target, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if path != tt.path || uri != tt.uri {
+				t.Errorf("expected %q (%q), got %q (%q)", tt.path, tt.uri, path, uri)
+			}
+		})
+	}
+}
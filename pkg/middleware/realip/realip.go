@@ -0,0 +1,111 @@
+package realip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type forwardedKey struct{}
+
+// Trusted replaces the remote address with the client address from the
+// forwarding headers, but only if the request has been received from one of
+// the trusted proxies. Without trusted proxies the headers get ignored.
+func Trusted(proxies []string) func(http.Handler) http.Handler {
+	networks := Networks(proxies)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if trusted(networks, peerIP(r)) {
+				r = r.WithContext(context.WithValue(r.Context(), forwardedKey{}, true))
+
+				if ip := clientIP(networks, r); ip != "" {
+					r.RemoteAddr = ip
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Forwarded checks if the request has been received from a trusted proxy,
+// only then other forwarding headers like X-Forwarded-Proto can be trusted.
+func Forwarded(r *http.Request) bool {
+	val, _ := r.Context().Value(forwardedKey{}).(bool)
+	return val
+}
+
+// Networks parses a list of IP addresses or CIDR ranges, invalid values are
+// skipped as they get reported by the config validation.
+func Networks(proxies []string) []*net.IPNet {
+	result := make([]*net.IPNet, 0, len(proxies))
+
+	for _, val := range proxies {
+		if !strings.Contains(val, "/") {
+			if ip := net.ParseIP(val); ip != nil {
+				if ip.To4() != nil {
+					val = val + "/32"
+				} else {
+					val = val + "/128"
+				}
+			}
+		}
+
+		if _, network, err := net.ParseCIDR(val); err == nil {
+			result = append(result, network)
+		}
+	}
+
+	return result
+}
+
+func peerIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+func clientIP(networks []*net.IPNet, r *http.Request) string {
+	if header := r.Header.Get("X-Forwarded-For"); header != "" {
+		hops := strings.Split(header, ",")
+
+		// walk from the nearest hop, the first untrusted one is the client
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+
+			if ip == nil {
+				return ""
+			}
+
+			if i == 0 || !trusted(networks, ip) {
+				return ip.String()
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+
+	return ""
+}
+
+func trusted(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
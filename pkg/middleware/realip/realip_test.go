@@ -0,0 +1,88 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrusted(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxies   []string
+		remote    string
+		forwarded string
+		real      string
+		want      string
+	}{
+		{"no trusted proxies", nil, "192.0.2.1:1234", "10.0.0.1", "", "192.0.2.1:1234"},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "192.0.2.1:1234", "10.0.0.1", "10.0.0.2", "192.0.2.1:1234"},
+		{"trusted peer", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "198.51.100.7", "", "198.51.100.7"},
+		{"trusted single address", []string{"10.0.0.5"}, "10.0.0.5:1234", "198.51.100.7", "", "198.51.100.7"},
+		{"spoofed first hop", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "10.1.1.1, 198.51.100.7", "", "198.51.100.7"},
+		{"chain of proxies", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "198.51.100.7, 10.0.0.9", "", "198.51.100.7"},
+		{"only proxies", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "10.0.0.8, 10.0.0.9", "", "10.0.0.8"},
+		{"invalid hop", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "garbage", "", "10.0.0.5:1234"},
+		{"real ip header", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "", "198.51.100.7", "198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+
+			handler := Trusted(tt.proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+
+			if tt.real != "" {
+				r.Header.Set("X-Real-IP", tt.real)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestForwarded(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxies   []string
+		remote    string
+		forwarded bool
+	}{
+		{"no trusted proxies", nil, "10.0.0.5:1234", false},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "192.0.2.1:1234", false},
+		{"trusted peer", []string{"10.0.0.0/8"}, "10.0.0.5:1234", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := false
+
+			handler := Trusted(tt.proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = Forwarded(r)
+			}))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			r.Header.Set("X-Forwarded-Proto", "https")
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.forwarded {
+				t.Errorf("expected %v, got %v", tt.forwarded, got)
+			}
+		})
+	}
+}
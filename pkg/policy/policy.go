@@ -0,0 +1,225 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+)
+
+var (
+	// ErrDenied gets returned if the session matches a deny rule.
+	ErrDenied = errors.New("access denied by policy")
+
+	// ErrNotAllowed gets returned if the session doesn't match any allow rule.
+	ErrNotAllowed = errors.New("access not allowed by policy")
+)
+
+// Policy evaluates the access rules of a route against a session.
+type Policy struct {
+	allow config.Rules
+	deny  config.Rules
+}
+
+// New prepares a policy based on the configuration.
+func New(cfg config.Policy) *Policy {
+	return &Policy{
+		allow: cfg.Allow,
+		deny:  cfg.Deny,
+	}
+}
+
+// Evaluate checks the session against the policy. Deny rules are evaluated
+// first and always win, afterwards any matching allow rule grants access.
+// Without any allow rules every authenticated session is granted access.
+func (p *Policy) Evaluate(s *session.Session) (string, error) {
+	if rule := Match(p.deny, s); rule != "" {
+		return rule, ErrDenied
+	}
+
+	if Empty(p.allow) {
+		return "", nil
+	}
+
+	if rule := Match(p.allow, s); rule != "" {
+		return rule, nil
+	}
+
+	return "", ErrNotAllowed
+}
+
+// Empty checks if the rules don't define any subject.
+func Empty(rules config.Rules) bool {
+	return len(rules.Users) == 0 &&
+		len(rules.Emails) == 0 &&
+		len(rules.Domains) == 0 &&
+		len(rules.Orgs) == 0 &&
+		len(rules.Teams) == 0 &&
+		len(rules.Groups) == 0 &&
+		len(rules.Workspaces) == 0 &&
+		len(rules.Claims) == 0
+}
+
+// Match returns the name of the first rule matching the session, it returns
+// an empty string if nothing matches. Values scoped like github:jdoe only
+// match sessions of that provider, emails and domains only match verified
+// emails. Teams only match GitHub sessions, groups only match Gitlab
+// sessions, workspaces only match Bitbucket sessions and claims only match
+// OpenID Connect sessions.
+func Match(rules config.Rules, s *session.Session) string {
+	switch {
+	case contains(scoped(rules.Users, s.Provider), s.Username):
+		return "users"
+	case s.EmailVerified && contains(scoped(rules.Emails, s.Provider), s.Email):
+		return "emails"
+	case s.EmailVerified && domain(scoped(rules.Domains, s.Provider), s.Email):
+		return "domains"
+	case intersect(scoped(rules.Orgs, s.Provider), s.Orgs):
+		return "orgs"
+	case s.Provider == "github" && intersect(rules.Teams, s.Orgs):
+		return "teams"
+	case s.Provider == "gitlab" && intersect(rules.Groups, s.Orgs):
+		return "groups"
+	case s.Provider == "bitbucket" && intersect(rules.Workspaces, s.Orgs):
+		return "workspaces"
+	case s.Provider == "oidc" && claims(rules.Claims, s.Claims):
+		return "claims"
+	}
+
+	return ""
+}
+
+// Claims returns the names of all claims referenced by the policies.
+func Claims(cfg *config.Config) []string {
+	result := []string{}
+
+	for _, p := range Policies(cfg) {
+		for _, rules := range []config.Rules{p.Allow, p.Deny} {
+			for name := range rules.Claims {
+				if !contains(result, name) {
+					result = append(result, name)
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// Memberships checks if any policy relies on the organizations, teams,
+// groups or workspaces fetched from the given provider.
+func Memberships(cfg *config.Config, provider string) bool {
+	for _, p := range Policies(cfg) {
+		for _, rules := range []config.Rules{p.Allow, p.Deny} {
+			if len(scoped(rules.Orgs, provider)) > 0 {
+				return true
+			}
+
+			switch provider {
+			case "github":
+				if len(rules.Teams) > 0 {
+					return true
+				}
+			case "gitlab":
+				if len(rules.Groups) > 0 {
+					return true
+				}
+			case "bitbucket":
+				if len(rules.Workspaces) > 0 {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Policies returns the policies of all configured routes.
+func Policies(cfg *config.Config) []config.Policy {
+	result := make([]config.Policy, 0, len(cfg.Proxy.Routes)+1)
+	result = append(result, cfg.Proxy.Policy)
+
+	for _, route := range cfg.Proxy.Routes {
+		result = append(result, route.Policy)
+	}
+
+	return result
+}
+
+// scoped returns the values applying to the provider, unscoped values apply
+// to all providers.
+func scoped(list []string, provider string) []string {
+	result := make([]string, 0, len(list))
+
+	for _, item := range list {
+		if scope, val := config.Scope(item); scope == "" || scope == provider {
+			result = append(result, val)
+		}
+	}
+
+	return result
+}
+
+func contains(list []string, val string) bool {
+	if val == "" {
+		return false
+	}
+
+	for _, item := range list {
+		if strings.EqualFold(item, val) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func intersect(list, vals []string) bool {
+	for _, val := range vals {
+		if contains(list, val) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func domain(list []string, email string) bool {
+	at := strings.LastIndex(email, "@")
+
+	if at < 0 {
+		return false
+	}
+
+	host := strings.ToLower(email[at+1:])
+
+	for _, item := range list {
+		item = strings.ToLower(strings.TrimPrefix(item, "@"))
+
+		if strings.HasPrefix(item, "*.") {
+			if strings.HasSuffix(host, item[1:]) {
+				return true
+			}
+
+			continue
+		}
+
+		if item == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+func claims(rules, vals map[string][]string) bool {
+	for name, allowed := range rules {
+		if intersect(allowed, vals[name]) {
+			return true
+		}
+	}
+
+	return false
+}
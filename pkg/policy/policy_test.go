@@ -0,0 +1,180 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+)
+
+func TestEvaluate(t *testing.T) {
+	github := &session.Session{
+		Provider:      "github",
+		Username:      "alice",
+		Email:         "alice@Example.com",
+		EmailVerified: true,
+		Orgs:          []string{"acme", "acme/ops"},
+	}
+
+	gitlab := &session.Session{
+		Provider: "gitlab",
+		Username: "alice",
+		Email:    "alice@example.com",
+		Orgs:     []string{"acme"},
+	}
+
+	oidc := &session.Session{
+		Provider: "oidc",
+		Username: "carol",
+		Email:    "carol@example.com",
+		Claims:   map[string][]string{"dept": {"eng"}},
+	}
+
+	tests := []struct {
+		name    string
+		policy  config.Policy
+		session *session.Session
+		rule    string
+		err     error
+	}{
+		{
+			name:    "empty",
+			session: github,
+		},
+		{
+			name:    "user",
+			policy:  config.Policy{Allow: config.Rules{Users: []string{"alice"}}},
+			session: github,
+			rule:    "users",
+		},
+		{
+			name:    "scoped user",
+			policy:  config.Policy{Allow: config.Rules{Users: []string{"github:alice"}}},
+			session: github,
+			rule:    "users",
+		},
+		{
+			name:    "scoped user other provider",
+			policy:  config.Policy{Allow: config.Rules{Users: []string{"github:alice"}}},
+			session: gitlab,
+			err:     ErrNotAllowed,
+		},
+		{
+			name:    "scoped deny other provider",
+			policy:  config.Policy{Deny: config.Rules{Users: []string{"gitlab:alice"}}},
+			session: github,
+		},
+		{
+			name:    "verified email",
+			policy:  config.Policy{Allow: config.Rules{Emails: []string{"alice@example.com"}}},
+			session: github,
+			rule:    "emails",
+		},
+		{
+			name:    "unverified email",
+			policy:  config.Policy{Allow: config.Rules{Emails: []string{"alice@example.com"}}},
+			session: gitlab,
+			err:     ErrNotAllowed,
+		},
+		{
+			name:    "verified domain",
+			policy:  config.Policy{Allow: config.Rules{Domains: []string{"example.com"}}},
+			session: github,
+			rule:    "domains",
+		},
+		{
+			name:    "unverified domain",
+			policy:  config.Policy{Allow: config.Rules{Domains: []string{"example.com"}}},
+			session: oidc,
+			err:     ErrNotAllowed,
+		},
+		{
+			name:    "scoped domain other provider",
+			policy:  config.Policy{Allow: config.Rules{Domains: []string{"gitlab:example.com"}}},
+			session: github,
+			err:     ErrNotAllowed,
+		},
+		{
+			name:    "scoped org",
+			policy:  config.Policy{Allow: config.Rules{Orgs: []string{"gitlab:acme"}}},
+			session: gitlab,
+			rule:    "orgs",
+		},
+		{
+			name:    "scoped org other provider",
+			policy:  config.Policy{Allow: config.Rules{Orgs: []string{"gitlab:acme"}}},
+			session: github,
+			err:     ErrNotAllowed,
+		},
+		{
+			name:    "team",
+			policy:  config.Policy{Allow: config.Rules{Teams: []string{"acme/ops"}}},
+			session: github,
+			rule:    "teams",
+		},
+		{
+			name:    "group on github",
+			policy:  config.Policy{Allow: config.Rules{Groups: []string{"acme"}}},
+			session: github,
+			err:     ErrNotAllowed,
+		},
+		{
+			name:    "group on gitlab",
+			policy:  config.Policy{Allow: config.Rules{Groups: []string{"acme"}}},
+			session: gitlab,
+			rule:    "groups",
+		},
+		{
+			name:    "claims",
+			policy:  config.Policy{Allow: config.Rules{Claims: map[string][]string{"dept": {"eng"}}}},
+			session: oidc,
+			rule:    "claims",
+		},
+		{
+			name: "deny wins",
+			policy: config.Policy{
+				Allow: config.Rules{Domains: []string{"example.com"}},
+				Deny:  config.Rules{Users: []string{"github:ALICE"}},
+			},
+			session: github,
+			rule:    "users",
+			err:     ErrDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := New(tt.policy).Evaluate(tt.session)
+
+			if err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if rule != tt.rule {
+				t.Errorf("expected rule %q, got %q", tt.rule, rule)
+			}
+		})
+	}
+}
+
+func TestDomain(t *testing.T) {
+	tests := []struct {
+		domains []string
+		email   string
+		match   bool
+	}{
+		{[]string{"example.com"}, "alice@example.com", true},
+		{[]string{"example.com"}, "alice@sub.example.com", false},
+		{[]string{"*.example.com"}, "alice@sub.example.com", true},
+		{[]string{"*.example.com"}, "alice@example.com", false},
+		{[]string{"example.com"}, "alice@example.com.evil", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			if got := domain(tt.domains, tt.email); got != tt.match {
+				t.Errorf("expected %v, got %v", tt.match, got)
+			}
+		})
+	}
+}
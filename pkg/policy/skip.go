@@ -0,0 +1,185 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+)
+
+// Skip matches requests which bypass the authentication.
+type Skip struct {
+	rules []skipRule
+}
+
+type skipRule struct {
+	name    string
+	method  string
+	pattern *regexp.Regexp
+	network *net.IPNet
+}
+
+// NewSkip compiles the skip-auth rules of the configuration.
+func NewSkip(rules []config.SkipAuth) (*Skip, error) {
+	s := &Skip{
+		rules: make([]skipRule, 0, len(rules)),
+	}
+
+	for _, rule := range rules {
+		compiled, err := compileSkip(rule)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s.rules = append(s.rules, compiled)
+	}
+
+	return s, nil
+}
+
+// Match returns the name of the first rule matching the request, it returns
+// an empty string if the request requires authentication.
+func (s *Skip) Match(r *http.Request) string {
+	if s == nil {
+		return ""
+	}
+
+	clean := CleanPath(r.URL.Path)
+
+	for _, rule := range s.rules {
+		if rule.method != "" && rule.method != r.Method {
+			continue
+		}
+
+		if !rule.pattern.MatchString(clean) {
+			continue
+		}
+
+		if rule.network != nil && !rule.network.Contains(remoteIP(r)) {
+			continue
+		}
+
+		return rule.name
+	}
+
+	return ""
+}
+
+// ParseSkip parses a skip-auth rule in the form of "[METHOD ]PATTERN[ CIDR]".
+func ParseSkip(val string) (config.SkipAuth, error) {
+	rule := config.SkipAuth{}
+	parts := strings.Fields(val)
+
+	if len(parts) > 1 && strings.ToUpper(parts[0]) == parts[0] && !strings.ContainsAny(parts[0], "/^*") {
+		rule.Method = parts[0]
+		parts = parts[1:]
+	}
+
+	if len(parts) > 1 {
+		if _, _, err := net.ParseCIDR(parts[len(parts)-1]); err == nil {
+			rule.CIDR = parts[len(parts)-1]
+			parts = parts[:len(parts)-1]
+		}
+	}
+
+	if len(parts) != 1 {
+		return rule, fmt.Errorf("invalid skip-auth rule %q", val)
+	}
+
+	rule.Path = parts[0]
+
+	if _, err := compileSkip(rule); err != nil {
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+func compileSkip(rule config.SkipAuth) (skipRule, error) {
+	result := skipRule{
+		name:   strings.TrimSpace(strings.Join([]string{rule.Method, rule.Path, rule.CIDR}, " ")),
+		method: strings.ToUpper(rule.Method),
+	}
+
+	pattern, err := regexp.Compile(expression(rule.Path))
+
+	if err != nil {
+		return result, fmt.Errorf("invalid skip-auth pattern %q: %s", rule.Path, err)
+	}
+
+	result.pattern = pattern
+
+	if rule.CIDR != "" {
+		_, network, err := net.ParseCIDR(rule.CIDR)
+
+		if err != nil {
+			return result, fmt.Errorf("invalid skip-auth cidr %q: %s", rule.CIDR, err)
+		}
+
+		result.network = network
+	}
+
+	return result, nil
+}
+
+// expression converts glob patterns to regular expressions, patterns
+// starting with ^ or ending with $ are treated as regular expressions.
+// Within globs ** matches across path segments, * and ? don't.
+func expression(pattern string) string {
+	if strings.HasPrefix(pattern, "^") || strings.HasSuffix(pattern, "$") {
+		return pattern
+	}
+
+	result := strings.Builder{}
+	result.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			result.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			result.WriteString("[^/]*")
+		case pattern[i] == '?':
+			result.WriteString("[^/]")
+		default:
+			result.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	result.WriteString("$")
+	return result.String()
+}
+
+// CleanPath resolves dot segments and duplicate slashes of the request path
+// while keeping a trailing slash, rules must never see the raw path.
+func CleanPath(val string) string {
+	if val == "" {
+		return "/"
+	}
+
+	clean := path.Clean("/" + val)
+
+	if strings.HasSuffix(val, "/") && clean != "/" {
+		clean = clean + "/"
+	}
+
+	return clean
+}
+
+// remoteIP returns the address of the client, forwarding headers are only
+// applied by the realip middleware for trusted proxies.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
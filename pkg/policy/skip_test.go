@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+)
+
+func TestParseSkip(t *testing.T) {
+	tests := []struct {
+		val   string
+		rule  config.SkipAuth
+		valid bool
+	}{
+		{"/health", config.SkipAuth{Path: "/health"}, true},
+		{"GET /hooks/**", config.SkipAuth{Method: "GET", Path: "/hooks/**"}, true},
+		{"POST /hooks/** 10.0.0.0/8", config.SkipAuth{Method: "POST", Path: "/hooks/**", CIDR: "10.0.0.0/8"}, true},
+		{"^/metrics$", config.SkipAuth{Path: "^/metrics$"}, true},
+		{"GET ^/broken( ", config.SkipAuth{}, false},
+		{"/first /second", config.SkipAuth{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			rule, err := ParseSkip(tt.val)
+
+			if tt.valid != (err == nil) {
+				t.Fatalf("expected valid=%v, got %v", tt.valid, err)
+			}
+
+			if tt.valid && (rule.Method != tt.rule.Method || rule.Path != tt.rule.Path || rule.CIDR != tt.rule.CIDR) {
+				t.Errorf("expected %+v, got %+v", tt.rule, rule)
+			}
+		})
+	}
+}
+
+func TestSkipMatch(t *testing.T) {
+	skip, err := NewSkip([]config.SkipAuth{
+		{Path: "/health"},
+		{Method: "POST", Path: "/hooks/**", CIDR: "10.0.0.0/8"},
+		{Path: "/public/*"},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to compile rules: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		remote string
+		skip   bool
+	}{
+		{"exact path", "GET", "/health", "192.0.2.1:1234", true},
+		{"dot segments", "GET", "/health/../admin", "192.0.2.1:1234", false},
+		{"dot segments into rule", "GET", "/admin/../health", "192.0.2.1:1234", true},
+		{"encoded dot segments", "GET", "/public/%2e%2e/admin", "192.0.2.1:1234", false},
+		{"duplicate slashes", "GET", "//health", "192.0.2.1:1234", true},
+		{"single segment glob", "GET", "/public/style.css", "192.0.2.1:1234", true},
+		{"nested segment glob", "GET", "/public/a/b", "192.0.2.1:1234", false},
+		{"network match", "POST", "/hooks/deploy", "10.1.2.3:1234", true},
+		{"network mismatch", "POST", "/hooks/deploy", "192.0.2.1:1234", false},
+		{"method mismatch", "GET", "/hooks/deploy", "10.1.2.3:1234", false},
+		{"escaping hooks", "POST", "/hooks/../admin", "10.1.2.3:1234", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://proxy.example.com"+tt.target, nil)
+			r.RemoteAddr = tt.remote
+
+			if matched := skip.Match(r) != ""; matched != tt.skip {
+				t.Errorf("expected skip=%v for %s %s", tt.skip, tt.method, tt.target)
+			}
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		val  string
+		want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"/a/b/", "/a/b/"},
+		{"/a/../b", "/b"},
+		{"/a/./b//c", "/a/b/c"},
+		{"/../../etc", "/etc"},
+		{"relative", "/relative"},
+	}
+
+	for _, tt := range tests {
+		if got := CleanPath(tt.val); got != tt.want {
+			t.Errorf("expected %q for %q, got %q", tt.want, tt.val, got)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrInvalidToken gets returned if no provider accepts the bearer token.
+	ErrInvalidToken = errors.New("token is not accepted by any provider")
+)
+
+const (
+	// bearerNegative defines how long rejected tokens get cached.
+	bearerNegative = 30 * time.Second
+
+	// bearerLimit defines the cache size which triggers a cleanup.
+	bearerLimit = 10000
+)
+
+type bearerEntry struct {
+	user    goth.User
+	orgs    []string
+	err     error
+	expires time.Time
+}
+
+var (
+	bearerCache = map[string]bearerEntry{}
+	bearerMutex = sync.Mutex{}
+)
+
+// Bearer validates the token including the organization checks, results get
+// cached for the given duration. The token only gets sent to a single
+// provider: JWTs to the OIDC provider matching the issuer, opaque tokens to
+// the given provider or the only registered one.
+func Bearer(token, name string, ttl time.Duration) (goth.User, []string, error) {
+	sum := sha256.Sum256([]byte(name + ":" + token))
+	key := hex.EncodeToString(sum[:])
+
+	bearerMutex.Lock()
+	entry, ok := bearerCache[key]
+	bearerMutex.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.user, entry.orgs, entry.err
+	}
+
+	entry = bearer(token, name)
+
+	if entry.err != nil && entry.err != ErrNotMember {
+		entry.expires = time.Now().Add(bearerNegative)
+	} else {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	bearerMutex.Lock()
+	defer bearerMutex.Unlock()
+
+	if len(bearerCache) >= bearerLimit {
+		now := time.Now()
+
+		for k, v := range bearerCache {
+			if now.After(v.expires) {
+				delete(bearerCache, k)
+			}
+		}
+	}
+
+	if len(bearerCache) < bearerLimit {
+		bearerCache[key] = entry
+	}
+
+	return entry.user, entry.orgs, entry.err
+}
+
+func bearer(token, name string) bearerEntry {
+	p, err := bearerProvider(token, name)
+
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("no provider selected for bearer token")
+
+		return bearerEntry{
+			err: ErrInvalidToken,
+		}
+	}
+
+	user, err := p.User(token)
+
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("provider", p.Name()).
+			Msg("bearer token not accepted")
+
+		return bearerEntry{
+			err: ErrInvalidToken,
+		}
+	}
+
+	orgs, err := Authorize(p.Name(), token)
+
+	return bearerEntry{
+		user: user,
+		orgs: orgs,
+		err:  err,
+	}
+}
+
+// bearerProvider selects the provider responsible for the token before any
+// request gets sent, tokens must never be presented to other providers.
+func bearerProvider(token, name string) (Provider, error) {
+	if issuer, ok := tokenIssuer(token); ok {
+		for _, p := range List() {
+			if o, ok := p.(*OIDC); ok && o.Issuer() == strings.TrimSuffix(issuer, "/") {
+				return p, nil
+			}
+		}
+
+		return nil, ErrUnknownProvider
+	}
+
+	if name != "" {
+		return Get(name)
+	}
+
+	if list := List(); len(list) == 1 {
+		return list[0], nil
+	}
+
+	return nil, ErrUnknownProvider
+}
+
+// tokenIssuer extracts the unverified issuer if the token is a JWT.
+func tokenIssuer(token string) (string, bool) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return "", false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+
+	if err != nil {
+		return "", false
+	}
+
+	claims := struct {
+		Issuer string `json:"iss"`
+	}{}
+
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Issuer == "" {
+		return "", false
+	}
+
+	return claims.Issuer, true
+}
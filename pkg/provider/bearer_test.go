@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer counts all requests which reached the fake provider.
+func countingServer(hits *int32, handler http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		handler(w, r)
+	}))
+}
+
+func unsignedToken(issuer string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"` + issuer + `","sub":"123"}`))
+
+	return header + "." + payload + ".signature"
+}
+
+func TestBearer(t *testing.T) {
+	var githubHits, gitlabHits, oidcHits int32
+
+	github := countingServer(&githubHits, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token opaque" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"id":5,"login":"jdoe"}`)
+		case "/user/orgs":
+			fmt.Fprint(w, `[{"login":"acme"}]`)
+		}
+	})
+
+	defer github.Close()
+
+	gitlab := countingServer(&gitlabHits, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	defer gitlab.Close()
+
+	var oidc *httptest.Server
+
+	oidc = countingServer(&oidcHits, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":"%[1]s","userinfo_endpoint":"%[1]s/userinfo","jwks_uri":"%[1]s/jwks"}`, oidc.URL)
+		case "/jwks":
+			fmt.Fprint(w, `{"keys":[]}`)
+		case "/userinfo":
+			fmt.Fprint(w, `{"sub":"123","preferred_username":"jdoe"}`)
+		}
+	})
+
+	defer oidc.Close()
+
+	tests := []struct {
+		name     string
+		token    string
+		selected string
+		err      error
+		github   int32
+		gitlab   int32
+		oidc     int32
+	}{
+		{"opaque without selection", "opaque", "", ErrInvalidToken, 0, 0, 0},
+		{"opaque with selection", "opaque", "github", nil, 2, 0, 0},
+		{"opaque rejected by selection", "invalid", "gitlab", ErrInvalidToken, 0, 1, 0},
+		{"jwt of known issuer", unsignedToken(oidc.URL), "github", nil, 0, 0, 3},
+		{"jwt of unknown issuer", unsignedToken("https://evil.example.com"), "github", ErrInvalidToken, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Clear()
+			bearerCache = map[string]bearerEntry{}
+			githubHits, gitlabHits, oidcHits = 0, 0, 0
+
+			Use(
+				&GitHub{URL: github.URL, Client: github.Client(), allowed: []string{"acme"}},
+				&Gitlab{URL: gitlab.URL, Client: gitlab.Client()},
+				&OIDC{Client: oidc.Client(), name: "oidc", issuer: oidc.URL},
+			)
+
+			user, _, err := Bearer(tt.token, tt.selected, time.Minute)
+
+			if err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err == nil && user.NickName != "jdoe" {
+				t.Errorf("expected user jdoe, got %q", user.NickName)
+			}
+
+			if githubHits != tt.github || gitlabHits != tt.gitlab || oidcHits != tt.oidc {
+				t.Errorf("expected requests github=%d gitlab=%d oidc=%d, got %d %d %d", tt.github, tt.gitlab, tt.oidc, githubHits, gitlabHits, oidcHits)
+			}
+		})
+	}
+}
+
+func TestBearerCache(t *testing.T) {
+	var hits int32
+
+	github := countingServer(&hits, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"id":5,"login":"jdoe"}`)
+		case "/user/orgs":
+			fmt.Fprint(w, `[]`)
+		}
+	})
+
+	defer github.Close()
+
+	Clear()
+	bearerCache = map[string]bearerEntry{}
+	Use(&GitHub{URL: github.URL, Client: github.Client(), allowed: []string{"acme"}})
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := Bearer("token", "", time.Minute); err != ErrNotMember {
+			t.Fatalf("expected membership denial, got %v", err)
+		}
+	}
+
+	if hits != 2 {
+		t.Errorf("expected cached result after the first lookup, got %d requests", hits)
+	}
+}
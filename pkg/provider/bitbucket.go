@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+)
+
+// Bitbucket implements the lookups for the Bitbucket API.
+type Bitbucket struct {
+	URL     string
+	Client  *http.Client
+	allowed []string
+}
+
+// NewBitbucket prepares a Bitbucket provider based on the configuration.
+func NewBitbucket(cfg *config.Config) *Bitbucket {
+	return &Bitbucket{
+		URL:     "https://api.bitbucket.org",
+		Client:  client(false),
+		allowed: cfg.Bitbucket.Orgs,
+	}
+}
+
+// Name returns the name of the provider.
+func (p *Bitbucket) Name() string {
+	return "bitbucket"
+}
+
+// Label returns the display name of the provider.
+func (p *Bitbucket) Label() string {
+	return "Bitbucket"
+}
+
+// Icon returns the asset name of the provider logo.
+func (p *Bitbucket) Icon() string {
+	return "bitbucket.svg"
+}
+
+// Allowed returns the list of allowed organizations.
+func (p *Bitbucket) Allowed() []string {
+	return p.allowed
+}
+
+// Orgs fetches the workspaces the user is a member of.
+func (p *Bitbucket) Orgs(token string) ([]string, error) {
+	result := []string{}
+	next := strings.TrimSuffix(p.URL, "/") + "/2.0/workspaces?role=member&pagelen=100"
+
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		records := struct {
+			Next   string `json:"next"`
+			Values []struct {
+				Slug string `json:"slug"`
+			} `json:"values"`
+		}{}
+
+		if _, err := fetch(p.Client, req, &records); err != nil {
+			return nil, err
+		}
+
+		for _, record := range records.Values {
+			result = append(result, record.Slug)
+		}
+
+		next = records.Next
+	}
+
+	return result, nil
+}
+
+// User fetches the user the access token belongs to.
+func (p *Bitbucket) User(token string) (goth.User, error) {
+	user := goth.User{
+		Provider:    p.Name(),
+		AccessToken: token,
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.URL, "/")+"/2.0/user", nil)
+
+	if err != nil {
+		return user, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	record := struct {
+		UUID        string `json:"uuid"`
+		Username    string `json:"username"`
+		Nickname    string `json:"nickname"`
+		DisplayName string `json:"display_name"`
+	}{}
+
+	if _, err := fetch(p.Client, req, &record); err != nil {
+		return user, err
+	}
+
+	user.UserID = record.UUID
+	user.NickName = record.Username
+	user.Name = record.DisplayName
+
+	if user.NickName == "" {
+		user.NickName = record.Nickname
+	}
+
+	return user, nil
+}
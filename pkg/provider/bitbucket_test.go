@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBitbucketAuthorize(t *testing.T) {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path != "/2.0/workspaces" || r.URL.Query().Get("role") != "member" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.URL.Query().Get("page") {
+		case "":
+			fmt.Fprintf(w, `{"next":"%s/2.0/workspaces?role=member&page=2","values":[{"slug":"acme"}]}`, srv.URL)
+		default:
+			fmt.Fprint(w, `{"values":[{"slug":"umbrella"}]}`)
+		}
+	}))
+
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		allowed []string
+		orgs    []string
+		fails   bool
+		err     error
+	}{
+		{
+			name:  "paginated workspaces",
+			token: "valid",
+			orgs:  []string{"acme", "umbrella"},
+		},
+		{
+			name:    "member",
+			token:   "valid",
+			allowed: []string{"umbrella"},
+			orgs:    []string{"acme", "umbrella"},
+		},
+		{
+			name:    "not member",
+			token:   "valid",
+			allowed: []string{"initech"},
+			orgs:    []string{"acme", "umbrella"},
+			err:     ErrNotMember,
+		},
+		{
+			name:    "invalid token with allowed workspaces",
+			token:   "invalid",
+			allowed: []string{"acme"},
+			fails:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Use(&Bitbucket{
+				URL:     srv.URL,
+				Client:  srv.Client(),
+				allowed: tt.allowed,
+			})
+
+			defer Clear()
+
+			orgs, err := Authorize("bitbucket", tt.token)
+
+			if tt.fails {
+				if err == nil || err == ErrNotMember {
+					t.Fatalf("expected request error, got %v", err)
+				}
+
+				return
+			}
+
+			if err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if !reflect.DeepEqual(orgs, tt.orgs) {
+				t.Errorf("expected workspaces %v, got %v", tt.orgs, orgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+)
+
+// GitHub implements the lookups for the GitHub API.
+type GitHub struct {
+	URL       string
+	Client    *http.Client
+	Teams     bool
+	clientKey string
+	secret    string
+	allowed   []string
+}
+
+// NewGitHub prepares a GitHub provider based on the configuration.
+func NewGitHub(cfg *config.Config) *GitHub {
+	return &GitHub{
+		URL:       "https://api.github.com",
+		Client:    client(false),
+		Teams:     policy.Memberships(cfg, "github"),
+		clientKey: cfg.GitHub.Client,
+		secret:    cfg.GitHub.Secret,
+		allowed:   cfg.GitHub.Orgs,
+	}
+}
+
+// Name returns the name of the provider.
+func (p *GitHub) Name() string {
+	return "github"
+}
+
+// Label returns the display name of the provider.
+func (p *GitHub) Label() string {
+	return "GitHub"
+}
+
+// Icon returns the asset name of the provider logo.
+func (p *GitHub) Icon() string {
+	return "github.svg"
+}
+
+// Allowed returns the list of allowed organizations.
+func (p *GitHub) Allowed() []string {
+	return p.allowed
+}
+
+// Revoke deletes the access token via the applications API.
+func (p *GitHub) Revoke(token string) error {
+	body, err := json.Marshal(map[string]string{
+		"access_token": token,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(
+		"DELETE",
+		strings.TrimSuffix(p.URL, "/")+"/applications/"+url.PathEscape(p.clientKey)+"/token",
+		bytes.NewReader(body),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(p.clientKey, p.secret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	return revoke(p.Client, req)
+}
+
+// Orgs fetches the organizations the user is a member of, if teams are
+// enabled they get appended in the form of org/team.
+func (p *GitHub) Orgs(token string) ([]string, error) {
+	result := []string{}
+	next := strings.TrimSuffix(p.URL, "/") + "/user/orgs?per_page=100"
+
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "token "+token)
+
+		records := []struct {
+			Login string `json:"login"`
+		}{}
+
+		resp, err := fetch(p.Client, req, &records)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			result = append(result, record.Login)
+		}
+
+		next = nextLink(resp.Header.Get("Link"))
+	}
+
+	if !p.Teams {
+		return result, nil
+	}
+
+	teams, err := p.teams(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(result, teams...), nil
+}
+
+// User fetches the user the access token belongs to.
+func (p *GitHub) User(token string) (goth.User, error) {
+	user := goth.User{
+		Provider:    p.Name(),
+		AccessToken: token,
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.URL, "/")+"/user", nil)
+
+	if err != nil {
+		return user, err
+	}
+
+	req.Header.Set("Authorization", "token "+token)
+
+	record := struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}{}
+
+	if _, err := fetch(p.Client, req, &record); err != nil {
+		return user, err
+	}
+
+	user.UserID = strconv.FormatInt(record.ID, 10)
+	user.NickName = record.Login
+	user.Name = record.Name
+	user.Email = record.Email
+
+	return user, nil
+}
+
+func (p *GitHub) teams(token string) ([]string, error) {
+	result := []string{}
+	next := strings.TrimSuffix(p.URL, "/") + "/user/teams?per_page=100"
+
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "token "+token)
+
+		records := []struct {
+			Slug         string `json:"slug"`
+			Organization struct {
+				Login string `json:"login"`
+			} `json:"organization"`
+		}{}
+
+		resp, err := fetch(p.Client, req, &records)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			result = append(result, record.Organization.Login+"/"+record.Slug)
+		}
+
+		next = nextLink(resp.Header.Get("Link"))
+	}
+
+	return result, nil
+}
+
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+
+		if len(parts) < 2 {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGitHubAuthorize(t *testing.T) {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/user/orgs" && r.URL.Query().Get("page") == "":
+			w.Header().Set("Link", fmt.Sprintf(`<%[1]s/user/orgs?page=2>; rel="next", <%[1]s/user/orgs?page=2>; rel="last"`, srv.URL))
+			fmt.Fprint(w, `[{"login":"Acme"}]`)
+		case r.URL.Path == "/user/orgs":
+			fmt.Fprint(w, `[{"login":"umbrella"}]`)
+		case r.URL.Path == "/user/teams":
+			fmt.Fprint(w, `[{"slug":"ops","organization":{"login":"acme"}}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		teams   bool
+		allowed []string
+		orgs    []string
+		fails   bool
+		err     error
+	}{
+		{
+			name:  "paginated orgs",
+			token: "valid",
+			orgs:  []string{"Acme", "umbrella"},
+		},
+		{
+			name:  "teams",
+			token: "valid",
+			teams: true,
+			orgs:  []string{"Acme", "umbrella", "acme/ops"},
+		},
+		{
+			name:    "member",
+			token:   "valid",
+			allowed: []string{"acme"},
+			orgs:    []string{"Acme", "umbrella"},
+		},
+		{
+			name:    "member of last page",
+			token:   "valid",
+			allowed: []string{"umbrella"},
+			orgs:    []string{"Acme", "umbrella"},
+		},
+		{
+			name:    "not member",
+			token:   "valid",
+			allowed: []string{"initech"},
+			orgs:    []string{"Acme", "umbrella"},
+			err:     ErrNotMember,
+		},
+		{
+			name:    "invalid token with allowed orgs",
+			token:   "invalid",
+			allowed: []string{"acme"},
+			fails:   true,
+		},
+		{
+			name:  "invalid token without allowed orgs",
+			token: "invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Use(&GitHub{
+				URL:     srv.URL,
+				Client:  srv.Client(),
+				Teams:   tt.teams,
+				allowed: tt.allowed,
+			})
+
+			defer Clear()
+
+			orgs, err := Authorize("github", tt.token)
+
+			if tt.fails {
+				if err == nil || err == ErrNotMember {
+					t.Fatalf("expected request error, got %v", err)
+				}
+
+				return
+			}
+
+			if err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if !reflect.DeepEqual(orgs, tt.orgs) {
+				t.Errorf("expected orgs %v, got %v", tt.orgs, orgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+)
+
+// Gitlab implements the lookups for the Gitlab API.
+type Gitlab struct {
+	URL       string
+	Client    *http.Client
+	clientKey string
+	secret    string
+	allowed   []string
+}
+
+// NewGitlab prepares a Gitlab provider based on the configuration.
+func NewGitlab(cfg *config.Config) *Gitlab {
+	return &Gitlab{
+		URL:       cfg.Gitlab.URL,
+		Client:    client(cfg.Gitlab.SkipVerify),
+		clientKey: cfg.Gitlab.Client,
+		secret:    cfg.Gitlab.Secret,
+		allowed:   cfg.Gitlab.Orgs,
+	}
+}
+
+// Name returns the name of the provider.
+func (p *Gitlab) Name() string {
+	return "gitlab"
+}
+
+// Label returns the display name of the provider.
+func (p *Gitlab) Label() string {
+	return "Gitlab"
+}
+
+// Icon returns the asset name of the provider logo.
+func (p *Gitlab) Icon() string {
+	return "gitlab.svg"
+}
+
+// Allowed returns the list of allowed organizations.
+func (p *Gitlab) Allowed() []string {
+	return p.allowed
+}
+
+// Revoke invalidates the access token via the OAuth2 revocation endpoint.
+func (p *Gitlab) Revoke(token string) error {
+	params := url.Values{}
+	params.Set("token", token)
+	params.Set("client_id", p.clientKey)
+	params.Set("client_secret", p.secret)
+
+	req, err := http.NewRequest(
+		"POST",
+		strings.TrimSuffix(p.URL, "/")+"/oauth/revoke",
+		strings.NewReader(params.Encode()),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return revoke(p.Client, req)
+}
+
+// Orgs fetches the groups the user is a member of.
+func (p *Gitlab) Orgs(token string) ([]string, error) {
+	result := []string{}
+	page := "1"
+
+	for page != "" {
+		params := url.Values{}
+		params.Set("min_access_level", "10")
+		params.Set("per_page", "100")
+		params.Set("page", page)
+
+		req, err := http.NewRequest("GET", strings.TrimSuffix(p.URL, "/")+"/api/v4/groups?"+params.Encode(), nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		records := []struct {
+			FullPath string `json:"full_path"`
+		}{}
+
+		resp, err := fetch(p.Client, req, &records)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			result = append(result, record.FullPath)
+		}
+
+		page = resp.Header.Get("X-Next-Page")
+	}
+
+	return result, nil
+}
+
+// User fetches the user the access token belongs to.
+func (p *Gitlab) User(token string) (goth.User, error) {
+	user := goth.User{
+		Provider:    p.Name(),
+		AccessToken: token,
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.URL, "/")+"/api/v4/user", nil)
+
+	if err != nil {
+		return user, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	record := struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+	}{}
+
+	if _, err := fetch(p.Client, req, &record); err != nil {
+		return user, err
+	}
+
+	user.UserID = strconv.FormatInt(record.ID, 10)
+	user.NickName = record.Username
+	user.Name = record.Name
+	user.Email = record.Email
+
+	return user, nil
+}
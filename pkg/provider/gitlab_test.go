@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGitlabAuthorize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path != "/api/v4/groups" || r.URL.Query().Get("min_access_level") != "10" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"full_path":"acme/platform"}]`)
+		default:
+			fmt.Fprint(w, `[{"full_path":"umbrella"}]`)
+		}
+	}))
+
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		allowed []string
+		orgs    []string
+		fails   bool
+		err     error
+	}{
+		{
+			name:  "paginated groups",
+			token: "valid",
+			orgs:  []string{"acme/platform", "umbrella"},
+		},
+		{
+			name:    "member of subgroup",
+			token:   "valid",
+			allowed: []string{"ACME/platform"},
+			orgs:    []string{"acme/platform", "umbrella"},
+		},
+		{
+			name:    "parent group is no match",
+			token:   "valid",
+			allowed: []string{"acme"},
+			orgs:    []string{"acme/platform", "umbrella"},
+			err:     ErrNotMember,
+		},
+		{
+			name:    "invalid token with allowed groups",
+			token:   "invalid",
+			allowed: []string{"umbrella"},
+			fails:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Use(&Gitlab{
+				URL:     srv.URL,
+				Client:  srv.Client(),
+				allowed: tt.allowed,
+			})
+
+			defer Clear()
+
+			orgs, err := Authorize("gitlab", tt.token)
+
+			if tt.fails {
+				if err == nil || err == ErrNotMember {
+					t.Fatalf("expected request error, got %v", err)
+				}
+
+				return
+			}
+
+			if err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if !reflect.DeepEqual(orgs, tt.orgs) {
+				t.Errorf("expected groups %v, got %v", tt.orgs, orgs)
+			}
+		})
+	}
+}
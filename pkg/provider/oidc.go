@@ -0,0 +1,564 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcLeeway defines the allowed clock skew for token validation.
+	oidcLeeway = time.Minute
+)
+
+// Discovery defines the relevant parts of the OpenID Connect discovery document.
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	EndSessionEndpoint    string   `json:"end_session_endpoint"`
+	RevocationEndpoint    string   `json:"revocation_endpoint"`
+	CodeChallenges        []string `json:"code_challenge_methods_supported"`
+}
+
+// OIDC implements a generic OpenID Connect provider.
+type OIDC struct {
+	Client        *http.Client
+	name          string
+	label         string
+	issuer        string
+	clientKey     string
+	secret        string
+	callbackURL   string
+	scopes        []string
+	usernameClaim string
+	groupsClaim   string
+	pkce          bool
+	allowed       []string
+
+	mutex     sync.Mutex
+	discovery *Discovery
+	keys      *jwt.KeySet
+}
+
+// NewOIDC prepares an OpenID Connect provider based on the configuration.
+func NewOIDC(cfg *config.Config) *OIDC {
+	scopes := cfg.OIDC.Scopes
+
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+
+	return &OIDC{
+		Client:        client(cfg.OIDC.SkipVerify),
+		name:          "oidc",
+		label:         cfg.OIDC.Label,
+		issuer:        strings.TrimSuffix(cfg.OIDC.Issuer, "/"),
+		clientKey:     cfg.OIDC.Client,
+		secret:        cfg.OIDC.Secret,
+		callbackURL:   fmt.Sprintf("%s%s/oidc/callback", cfg.Server.Host, cfg.Server.Root),
+		scopes:        scopes,
+		usernameClaim: cfg.OIDC.UsernameClaim,
+		groupsClaim:   cfg.OIDC.GroupsClaim,
+		pkce:          cfg.OIDC.PKCE,
+		allowed:       cfg.OIDC.Orgs,
+	}
+}
+
+// Name returns the name of the provider.
+func (p *OIDC) Name() string {
+	return p.name
+}
+
+// Label returns the configured display name of the provider.
+func (p *OIDC) Label() string {
+	if p.label == "" {
+		return "OpenID Connect"
+	}
+
+	return p.label
+}
+
+// Issuer returns the issuer URL of the provider.
+func (p *OIDC) Issuer() string {
+	return p.issuer
+}
+
+// Icon returns no asset, the label gets displayed instead.
+func (p *OIDC) Icon() string {
+	return ""
+}
+
+// SetName sets the name of the provider.
+func (p *OIDC) SetName(name string) {
+	p.name = name
+}
+
+// Debug is a no-op to fulfill the goth provider interface.
+func (p *OIDC) Debug(debug bool) {}
+
+// Allowed returns the list of allowed groups.
+func (p *OIDC) Allowed() []string {
+	return p.allowed
+}
+
+// Discover fetches and caches the discovery document of the issuer.
+func (p *OIDC) Discover() (*Discovery, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequest("GET", p.issuer+"/.well-known/openid-configuration", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	discovery := &Discovery{}
+
+	if _, err := fetch(p.Client, req, discovery); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("issuer %s doesn't match discovered %s", p.issuer, discovery.Issuer)
+	}
+
+	p.discovery = discovery
+	p.keys = jwt.NewKeySet(discovery.JWKSURI, p.Client)
+
+	return p.discovery, nil
+}
+
+// BeginAuth prepares the authorization URL including a nonce and a PKCE
+// challenge if the issuer supports it.
+func (p *OIDC) BeginAuth(state string) (goth.Session, error) {
+	cfg, err := p.config()
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &OIDCSession{
+		Nonce: nonce,
+	}
+
+	opts := []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("nonce", nonce),
+	}
+
+	if p.challenge() {
+		if s.Verifier, err = randomString(); err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256([]byte(s.Verifier))
+
+		opts = append(
+			opts,
+			oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:])),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+
+	s.AuthURL = cfg.AuthCodeURL(state, opts...)
+	return s, nil
+}
+
+// UnmarshalSession decodes the stored session.
+func (p *OIDC) UnmarshalSession(data string) (goth.Session, error) {
+	s := &OIDCSession{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+
+	return s, err
+}
+
+// FetchUser maps the claims of the verified ID token to the user.
+func (p *OIDC) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*OIDCSession)
+
+	user := goth.User{
+		Provider:     p.Name(),
+		AccessToken:  s.AccessToken,
+		RefreshToken: s.RefreshToken,
+		IDToken:      s.IDToken,
+		ExpiresAt:    s.ExpiresAt,
+	}
+
+	if s.IDToken == "" {
+		return user, fmt.Errorf("%s cannot get user information without id token", p.Name())
+	}
+
+	claims, err := p.Verify(s.IDToken, "")
+
+	if err != nil {
+		return user, err
+	}
+
+	user.RawData = claims
+	user.UserID = claims.String("sub")
+	user.Email = claims.String("email")
+	user.Name = claims.String("name")
+	user.FirstName = claims.String("given_name")
+	user.LastName = claims.String("family_name")
+	user.AvatarURL = claims.String("picture")
+	user.NickName = p.username(claims)
+
+	return user, nil
+}
+
+// RefreshTokenAvailable signals that refresh tokens are supported.
+func (p *OIDC) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken fetches a new access token based on the refresh token.
+func (p *OIDC) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	cfg, err := p.config()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.TokenSource(
+		p.context(),
+		&oauth2.Token{
+			RefreshToken: refreshToken,
+		},
+	).Token()
+}
+
+// Revoke invalidates the access token via the revocation endpoint.
+func (p *OIDC) Revoke(token string) error {
+	discovery, err := p.Discover()
+
+	if err != nil {
+		return err
+	}
+
+	if discovery.RevocationEndpoint == "" {
+		return ErrNotSupported
+	}
+
+	params := url.Values{}
+	params.Set("token", token)
+	params.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequest(
+		"POST",
+		discovery.RevocationEndpoint,
+		strings.NewReader(params.Encode()),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(url.QueryEscape(p.clientKey), url.QueryEscape(p.secret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return revoke(p.Client, req)
+}
+
+// EndSession builds the URL for RP-initiated logout at the issuer.
+func (p *OIDC) EndSession(idToken, redirect string) (string, error) {
+	discovery, err := p.Discover()
+
+	if err != nil {
+		return "", err
+	}
+
+	if discovery.EndSessionEndpoint == "" {
+		return "", ErrNotSupported
+	}
+
+	target, err := url.Parse(discovery.EndSessionEndpoint)
+
+	if err != nil {
+		return "", err
+	}
+
+	params := target.Query()
+	params.Set("client_id", p.clientKey)
+
+	if idToken != "" {
+		params.Set("id_token_hint", idToken)
+	}
+
+	if redirect != "" {
+		params.Set("post_logout_redirect_uri", redirect)
+	}
+
+	target.RawQuery = params.Encode()
+	return target.String(), nil
+}
+
+// Verify checks the signature and the claims of an ID token.
+func (p *OIDC) Verify(raw, nonce string) (jwt.Claims, error) {
+	discovery, err := p.Discover()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := p.keys.Verify(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Validate(oidcLeeway); err != nil {
+		return nil, err
+	}
+
+	if t.Claims.String("iss") != discovery.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %s", t.Claims.String("iss"))
+	}
+
+	if !contains(t.Claims.Strings("aud"), p.clientKey) {
+		return nil, errors.New("token is not issued for this client")
+	}
+
+	if t.Claims.Time("exp").IsZero() {
+		return nil, errors.New("token is missing the expiry")
+	}
+
+	if nonce != "" && t.Claims.String("nonce") != nonce {
+		return nil, errors.New("token nonce doesn't match")
+	}
+
+	return t.Claims, nil
+}
+
+// Orgs extracts the groups from an ID token or fetches them from the
+// userinfo endpoint for an opaque access token.
+func (p *OIDC) Orgs(token string) ([]string, error) {
+	claims, err := p.claims(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return claims.Strings(p.groupsClaim), nil
+}
+
+// User validates an ID token or queries the userinfo endpoint for an
+// opaque access token.
+func (p *OIDC) User(token string) (goth.User, error) {
+	user := goth.User{
+		Provider:    p.Name(),
+		AccessToken: token,
+	}
+
+	claims, err := p.claims(token)
+
+	if err != nil {
+		return user, err
+	}
+
+	user.RawData = claims
+	user.UserID = claims.String("sub")
+	user.Email = claims.String("email")
+	user.Name = claims.String("name")
+	user.NickName = p.username(claims)
+
+	return user, nil
+}
+
+func (p *OIDC) claims(token string) (jwt.Claims, error) {
+	if claims, err := p.Verify(token, ""); err == nil {
+		return claims, nil
+	}
+
+	discovery, err := p.Discover()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if discovery.UserinfoEndpoint == "" {
+		return nil, errors.New("issuer doesn't provide a userinfo endpoint")
+	}
+
+	req, err := http.NewRequest("GET", discovery.UserinfoEndpoint, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	claims := jwt.Claims{}
+
+	if _, err := fetch(p.Client, req, &claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (p *OIDC) username(claims jwt.Claims) string {
+	for _, name := range []string{p.usernameClaim, "preferred_username", "sub"} {
+		if val := claims.String(name); val != "" {
+			return val
+		}
+	}
+
+	return ""
+}
+
+func (p *OIDC) config() (*oauth2.Config, error) {
+	discovery, err := p.Discover()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.clientKey,
+		ClientSecret: p.secret,
+		RedirectURL:  p.callbackURL,
+		Scopes:       p.scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}, nil
+}
+
+// challenge checks if PKCE is enabled and S256 is supported by the issuer,
+// issuers not announcing any methods are expected to support it.
+func (p *OIDC) challenge() bool {
+	if !p.pkce {
+		return false
+	}
+
+	discovery, err := p.Discover()
+
+	if err != nil {
+		return false
+	}
+
+	return len(discovery.CodeChallenges) == 0 || contains(discovery.CodeChallenges, "S256")
+}
+
+func (p *OIDC) context() context.Context {
+	return context.WithValue(
+		context.Background(),
+		oauth2.HTTPClient,
+		p.Client,
+	)
+}
+
+// OIDCSession stores the state of the OpenID Connect authorization.
+type OIDCSession struct {
+	AuthURL      string
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	ExpiresAt    time.Time
+	Nonce        string
+	Verifier     string
+}
+
+// GetAuthURL returns the authorization URL.
+func (s *OIDCSession) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+
+	return s.AuthURL, nil
+}
+
+// Authorize exchanges the code and verifies the returned ID token.
+func (s *OIDCSession) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*OIDC)
+	cfg, err := p.config()
+
+	if err != nil {
+		return "", err
+	}
+
+	opts := []oauth2.AuthCodeOption{}
+
+	if s.Verifier != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", s.Verifier))
+	}
+
+	token, err := cfg.Exchange(p.context(), params.Get("code"), opts...)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid() {
+		return "", errors.New("invalid token received from provider")
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+
+	if !ok || raw == "" {
+		return "", errors.New("no id token received from provider")
+	}
+
+	if _, err := p.Verify(raw, s.Nonce); err != nil {
+		return "", err
+	}
+
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.IDToken = raw
+	s.ExpiresAt = token.Expiry
+
+	return token.AccessToken, nil
+}
+
+// Marshal encodes the session to be stored.
+func (s *OIDCSession) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func contains(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,273 @@
+package provider
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+)
+
+// oidcIssuer serves discovery, keys and tokens of a fake OpenID Connect issuer.
+type oidcIssuer struct {
+	*httptest.Server
+
+	hits  int32
+	mutex sync.Mutex
+	keys  map[string]*rsa.PrivateKey
+	nonce string
+}
+
+func newOIDCIssuer(t *testing.T) *oidcIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	i := &oidcIssuer{
+		keys: map[string]*rsa.PrivateKey{"first": key},
+	}
+
+	i.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i.mutex.Lock()
+		defer i.mutex.Unlock()
+
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":"%[1]s","authorization_endpoint":"%[1]s/auth","token_endpoint":"%[1]s/token","jwks_uri":"%[1]s/keys"}`, i.URL)
+		case "/keys":
+			atomic.AddInt32(&i.hits, 1)
+
+			set := jwt.JWKS{}
+
+			for kid, key := range i.keys {
+				set.Keys = append(set.Keys, jwt.JWK{
+					KeyID:   kid,
+					KeyType: "RSA",
+					N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				})
+			}
+
+			json.NewEncoder(w).Encode(set)
+		case "/token":
+			token := i.sign(t, "RS256", "first", i.claims(map[string]interface{}{"nonce": i.nonce}))
+
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600,"id_token":"%s"}`, token)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return i
+}
+
+// claims returns valid claims for the test client, merged with overrides.
+func (i *oidcIssuer) claims(overrides map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":                i.URL,
+		"aud":                "client",
+		"sub":                "123",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"preferred_username": "jdoe",
+	}
+
+	for key, val := range overrides {
+		if val == nil {
+			delete(claims, key)
+			continue
+		}
+
+		claims[key] = val
+	}
+
+	return claims
+}
+
+// sign creates a token signed by the key with the given id, HS256 tokens
+// get signed with the client secret to simulate algorithm confusion.
+func (i *oidcIssuer) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	c, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	signature := []byte{}
+
+	switch alg {
+	case "RS256":
+		key, ok := i.keys[kid]
+
+		if !ok {
+			key, _ = rsa.GenerateKey(rand.Reader, 2048)
+		}
+
+		sum := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+
+		signature = sig
+	case "HS256":
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func testOIDC(issuer *oidcIssuer) *OIDC {
+	return &OIDC{
+		Client:    issuer.Client(),
+		name:      "oidc",
+		issuer:    issuer.URL,
+		clientKey: "client",
+		secret:    "secret",
+	}
+}
+
+func TestOIDCVerify(t *testing.T) {
+	issuer := newOIDCIssuer(t)
+	defer issuer.Close()
+
+	p := testOIDC(issuer)
+
+	tests := []struct {
+		name   string
+		alg    string
+		kid    string
+		claims map[string]interface{}
+		nonce  string
+		valid  bool
+	}{
+		{"valid", "RS256", "first", nil, "", true},
+		{"audience list", "RS256", "first", map[string]interface{}{"aud": []string{"other", "client"}}, "", true},
+		{"wrong audience", "RS256", "first", map[string]interface{}{"aud": "other"}, "", false},
+		{"missing audience", "RS256", "first", map[string]interface{}{"aud": nil}, "", false},
+		{"wrong issuer", "RS256", "first", map[string]interface{}{"iss": "https://evil.example.com"}, "", false},
+		{"expired", "RS256", "first", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, "", false},
+		{"missing expiry", "RS256", "first", map[string]interface{}{"exp": nil}, "", false},
+		{"not yet valid", "RS256", "first", map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()}, "", false},
+		{"nonce", "RS256", "first", map[string]interface{}{"nonce": "abc"}, "abc", true},
+		{"nonce mismatch", "RS256", "first", map[string]interface{}{"nonce": "abc"}, "xyz", false},
+		{"missing nonce", "RS256", "first", nil, "xyz", false},
+		{"forged signature", "RS256", "forged", nil, "", false},
+		{"hmac with client secret", "HS256", "first", nil, "", false},
+		{"none", "none", "first", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Verify(issuer.sign(t, tt.alg, tt.kid, issuer.claims(tt.claims)), tt.nonce)
+
+			if tt.valid != (err == nil) {
+				t.Errorf("expected valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	issuer := newOIDCIssuer(t)
+	defer issuer.Close()
+
+	p := testOIDC(issuer)
+
+	if _, err := p.Verify(issuer.sign(t, "RS256", "first", issuer.claims(nil)), ""); err != nil {
+		t.Fatalf("expected valid token, got %s", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	issuer.mutex.Lock()
+	issuer.keys["second"] = key
+	issuer.mutex.Unlock()
+
+	p.keys.Refresh = 0
+
+	if _, err := p.Verify(issuer.sign(t, "RS256", "second", issuer.claims(nil)), ""); err != nil {
+		t.Fatalf("expected keys to be refetched for an unknown kid, got %s", err)
+	}
+
+	if hits := atomic.LoadInt32(&issuer.hits); hits != 2 {
+		t.Errorf("expected two key fetches, got %d", hits)
+	}
+
+	p.keys.Refresh = time.Hour
+
+	if _, err := p.Verify(issuer.sign(t, "RS256", "third", issuer.claims(nil)), ""); err != jwt.ErrUnknownKey {
+		t.Errorf("expected %v, got %v", jwt.ErrUnknownKey, err)
+	}
+
+	if hits := atomic.LoadInt32(&issuer.hits); hits != 2 {
+		t.Errorf("expected no refetch within the refresh interval, got %d fetches", hits)
+	}
+}
+
+func TestOIDCAuthorize(t *testing.T) {
+	issuer := newOIDCIssuer(t)
+	defer issuer.Close()
+
+	p := testOIDC(issuer)
+
+	tests := []struct {
+		name  string
+		nonce func(s *OIDCSession) string
+		valid bool
+	}{
+		{"matching nonce", func(s *OIDCSession) string { return s.Nonce }, true},
+		{"replayed nonce", func(s *OIDCSession) string { return "replayed" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, err := p.BeginAuth("state")
+
+			if err != nil {
+				t.Fatalf("failed to begin auth: %s", err)
+			}
+
+			s := sess.(*OIDCSession)
+			target, _ := s.GetAuthURL()
+
+			if u, err := url.Parse(target); err != nil || u.Query().Get("nonce") != s.Nonce || s.Nonce == "" {
+				t.Fatalf("expected nonce %q in auth url, got %s", s.Nonce, target)
+			}
+
+			issuer.mutex.Lock()
+			issuer.nonce = tt.nonce(s)
+			issuer.mutex.Unlock()
+
+			_, err = s.Authorize(p, url.Values{"code": {"code"}})
+
+			if tt.valid != (err == nil) {
+				t.Fatalf("expected valid=%v, got %v", tt.valid, err)
+			}
+
+			if tt.valid && (s.AccessToken != "access" || s.IDToken == "") {
+				t.Errorf("expected tokens to be stored, got %+v", s)
+			}
+		})
+	}
+}
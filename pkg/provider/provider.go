@@ -0,0 +1,320 @@
+package provider
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrNotMember gets returned if the user is not part of an allowed organization.
+	ErrNotMember = errors.New("user is not a member of an allowed organization")
+
+	// ErrUnknownProvider gets returned if the requested provider is not registered.
+	ErrUnknownProvider = errors.New("provider is not registered")
+
+	// ErrNotSupported gets returned if the provider doesn't support an action.
+	ErrNotSupported = errors.New("action is not supported by provider")
+)
+
+var (
+	providers = map[string]Provider{}
+	auths     = map[string]goth.Provider{}
+	mutex     = sync.RWMutex{}
+)
+
+// Provider defines the provider specific API lookups.
+type Provider interface {
+	// Name returns the name of the provider, matching the goth provider name.
+	Name() string
+
+	// Label returns the display name of the provider for the login page.
+	Label() string
+
+	// Icon returns the asset name of the provider logo, empty if not available.
+	Icon() string
+
+	// Allowed returns the list of allowed organizations.
+	Allowed() []string
+
+	// Orgs fetches the organizations the user is a member of.
+	Orgs(token string) ([]string, error)
+
+	// User fetches the user the access token belongs to.
+	User(token string) (goth.User, error)
+}
+
+// Revoker gets implemented by providers which are able to revoke tokens.
+type Revoker interface {
+	// Revoke invalidates the access token at the provider.
+	Revoke(token string) error
+}
+
+// Terminator gets implemented by providers which are able to terminate the
+// session at the provider.
+type Terminator interface {
+	// EndSession returns the URL to terminate the session at the provider,
+	// the user gets redirected back to the given URL afterwards.
+	EndSession(idToken, redirect string) (string, error)
+}
+
+// Set collects providers which get activated together.
+type Set struct {
+	providers map[string]Provider
+	auths     map[string]goth.Provider
+}
+
+// NewSet prepares an empty set of providers.
+func NewSet() *Set {
+	return &Set{
+		providers: map[string]Provider{},
+		auths:     map[string]goth.Provider{},
+	}
+}
+
+// Add adds the provider lookups together with the goth provider handling
+// the login flow.
+func (s *Set) Add(p Provider, auth goth.Provider) {
+	s.providers[p.Name()] = p
+	s.auths[p.Name()] = auth
+}
+
+// Replace activates the set at once, concurrent requests either see the
+// previous or the new set of providers.
+func Replace(s *Set) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	providers = s.providers
+	auths = s.auths
+}
+
+// Use registers the given providers for lookups, providers implementing the
+// goth interface get also used for the login flow.
+func Use(list ...Provider) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, p := range list {
+		providers[p.Name()] = p
+
+		if auth, ok := p.(goth.Provider); ok {
+			auths[p.Name()] = auth
+		}
+	}
+}
+
+// Clear removes all registered providers.
+func Clear() {
+	Replace(NewSet())
+}
+
+// Auth returns the goth provider handling the login flow by name.
+func Auth(name string) (goth.Provider, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if p, ok := auths[name]; ok {
+		return p, nil
+	}
+
+	return nil, ErrUnknownProvider
+}
+
+// Get returns a registered provider by name.
+func Get(name string) (Provider, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if p, ok := providers[name]; ok {
+		return p, nil
+	}
+
+	return nil, ErrUnknownProvider
+}
+
+// List returns all registered providers sorted by name.
+func List() []Provider {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names := make([]string, 0, len(providers))
+
+	for name := range providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	result := make([]Provider, 0, len(names))
+
+	for _, name := range names {
+		result = append(result, providers[name])
+	}
+
+	return result
+}
+
+// Authorize fetches the organizations of the user and checks them against
+// the list of allowed organizations of the provider.
+func Authorize(name, token string) ([]string, error) {
+	p, err := Get(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	orgs, err := p.Orgs(token)
+
+	if err != nil {
+		if len(p.Allowed()) == 0 {
+			log.Debug().
+				Err(err).
+				Str("provider", name).
+				Msg("failed to fetch organizations")
+
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if len(p.Allowed()) > 0 && !Member(orgs, p.Allowed()) {
+		return orgs, ErrNotMember
+	}
+
+	return orgs, nil
+}
+
+// Revoke invalidates the access token if the provider supports it.
+func Revoke(name, token string) error {
+	p, err := Get(name)
+
+	if err != nil {
+		return err
+	}
+
+	if r, ok := p.(Revoker); ok {
+		return r.Revoke(token)
+	}
+
+	return ErrNotSupported
+}
+
+// EndSession returns the URL to terminate the session at the provider if
+// the provider supports it.
+func EndSession(name, idToken, redirect string) (string, error) {
+	p, err := Get(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	if t, ok := p.(Terminator); ok {
+		return t.EndSession(idToken, redirect)
+	}
+
+	return "", ErrNotSupported
+}
+
+// EmailVerified checks if the email of the user has been verified. GitHub,
+// Gitlab and Bitbucket only expose confirmed primary emails, OpenID Connect
+// providers have to set the email_verified claim.
+func EmailVerified(user goth.User) bool {
+	if user.Email == "" {
+		return false
+	}
+
+	p, err := Get(user.Provider)
+
+	if err != nil {
+		return false
+	}
+
+	if _, ok := p.(*OIDC); !ok {
+		return true
+	}
+
+	switch val := user.RawData["email_verified"].(type) {
+	case bool:
+		return val
+	case string:
+		return strings.EqualFold(val, "true")
+	}
+
+	return false
+}
+
+// Member checks if any of the organizations is part of the allowed list.
+func Member(orgs, allowed []string) bool {
+	for _, org := range orgs {
+		for _, allow := range allowed {
+			if strings.EqualFold(org, allow) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func client(skipVerify bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if skipVerify {
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+	}
+}
+
+func revoke(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Path)
+	}
+
+	return nil
+}
+
+func fetch(client *http.Client, req *http.Request, val interface{}) (*http.Response, error) {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Path)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(val); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	first := NewSet()
+	first.Add(&GitHub{}, nil)
+	first.Add(&Gitlab{}, nil)
+
+	second := NewSet()
+	second.Add(&Bitbucket{}, nil)
+
+	Replace(first)
+
+	wg := sync.WaitGroup{}
+	done := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if n := len(List()); n != 1 && n != 2 {
+					t.Errorf("expected a complete set of providers, got %d", n)
+					return
+				}
+
+				Get("github")
+				Auth("bitbucket")
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			Replace(second)
+		} else {
+			Replace(first)
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	tests := []struct {
+		name       string
+		registered bool
+	}{
+		{"github", true},
+		{"gitlab", true},
+		{"bitbucket", false},
+	}
+
+	for _, tt := range tests {
+		if _, err := Get(tt.name); (err == nil) != tt.registered {
+			t.Errorf("expected %s registered=%v, got %v", tt.name, tt.registered, err)
+		}
+	}
+}
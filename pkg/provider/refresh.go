@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrInvalidGrant gets returned if the provider rejected the refresh token.
+	ErrInvalidGrant = errors.New("refresh token has been rejected")
+)
+
+const (
+	// refreshReuse defines how long a refresh result gets shared, parallel
+	// requests still presenting the old session get the same result.
+	refreshReuse = 30 * time.Second
+)
+
+type refreshEntry struct {
+	token   *oauth2.Token
+	orgs    []string
+	err     error
+	done    chan struct{}
+	expires time.Time
+}
+
+var (
+	refreshCache = map[string]*refreshEntry{}
+	refreshMutex = sync.Mutex{}
+)
+
+// Refresh renews the access token with the refresh token and checks the
+// organization membership again, concurrent refreshes of the same token
+// are executed only once.
+func Refresh(name, refreshToken string) (*oauth2.Token, []string, error) {
+	sum := sha256.Sum256([]byte(name + ":" + refreshToken))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	refreshMutex.Lock()
+	entry, ok := refreshCache[key]
+
+	if !ok || (!entry.expires.IsZero() && now.After(entry.expires)) {
+		for k, v := range refreshCache {
+			if !v.expires.IsZero() && now.After(v.expires) {
+				delete(refreshCache, k)
+			}
+		}
+
+		entry = &refreshEntry{
+			done: make(chan struct{}),
+		}
+
+		refreshCache[key] = entry
+		refreshMutex.Unlock()
+
+		entry.token, entry.orgs, entry.err = refresh(name, refreshToken)
+
+		refreshMutex.Lock()
+		entry.expires = time.Now().Add(refreshReuse)
+		refreshMutex.Unlock()
+
+		close(entry.done)
+	} else {
+		refreshMutex.Unlock()
+	}
+
+	<-entry.done
+	return entry.token, entry.orgs, entry.err
+}
+
+func refresh(name, refreshToken string) (*oauth2.Token, []string, error) {
+	p, err := Auth(name)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !p.RefreshTokenAvailable() {
+		return nil, nil, ErrNotSupported
+	}
+
+	token, err := p.RefreshToken(refreshToken)
+
+	if err != nil {
+		if invalidGrant(err) {
+			return nil, nil, ErrInvalidGrant
+		}
+
+		return nil, nil, err
+	}
+
+	if token.AccessToken == "" {
+		return nil, nil, errors.New("no access token received from provider")
+	}
+
+	orgs, err := Authorize(name, token.AccessToken)
+	return token, orgs, err
+}
+
+// invalidGrant checks if the token endpoint rejected the refresh token, any
+// other error like a timeout or a server error is only temporary.
+func invalidGrant(err error) bool {
+	e, ok := err.(*oauth2.RetrieveError)
+
+	if !ok {
+		return false
+	}
+
+	record := struct {
+		Error string `json:"error"`
+	}{}
+
+	if json.Unmarshal(e.Body, &record) != nil {
+		if vals, err := url.ParseQuery(string(e.Body)); err == nil {
+			record.Error = vals.Get("error")
+		}
+	}
+
+	return record.Error == "invalid_grant"
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// revalidateNegative defines how long failed lookups get cached.
+	revalidateNegative = 30 * time.Second
+
+	// revalidateLimit defines the cache size which triggers a cleanup.
+	revalidateLimit = 10000
+)
+
+type revalidateEntry struct {
+	orgs    []string
+	err     error
+	expires time.Time
+}
+
+var (
+	revalidateCache = map[string]revalidateEntry{}
+	revalidateMutex = sync.Mutex{}
+)
+
+// Revalidate checks the organization membership of the token owner again,
+// results get cached for the given duration to respect API rate limits.
+func Revalidate(name, token string, ttl time.Duration) ([]string, error) {
+	sum := sha256.Sum256([]byte(name + ":" + token))
+	key := hex.EncodeToString(sum[:])
+
+	revalidateMutex.Lock()
+	entry, ok := revalidateCache[key]
+	revalidateMutex.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.orgs, entry.err
+	}
+
+	entry.orgs, entry.err = Authorize(name, token)
+
+	if entry.err != nil && entry.err != ErrNotMember {
+		entry.expires = time.Now().Add(revalidateNegative)
+	} else {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	revalidateMutex.Lock()
+	defer revalidateMutex.Unlock()
+
+	if len(revalidateCache) >= revalidateLimit {
+		now := time.Now()
+
+		for k, v := range revalidateCache {
+			if now.After(v.expires) {
+				delete(revalidateCache, k)
+			}
+		}
+	}
+
+	if len(revalidateCache) < revalidateLimit {
+		revalidateCache[key] = entry
+	}
+
+	return entry.orgs, entry.err
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Reloadable wraps a handler which can be replaced at runtime, requests
+// already in flight finish with the previous handler.
+type Reloadable struct {
+	handler atomic.Value
+}
+
+// NewReloadable prepares a reloadable handler.
+func NewReloadable(handler http.Handler) *Reloadable {
+	r := &Reloadable{}
+	r.Store(handler)
+
+	return r
+}
+
+// Store replaces the wrapped handler.
+func (r *Reloadable) Store(handler http.Handler) {
+	r.handler.Store(&handler)
+}
+
+// ServeHTTP passes the request to the current handler.
+func (r *Reloadable) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	(*r.handler.Load().(*http.Handler)).ServeHTTP(w, req)
+}
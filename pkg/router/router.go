@@ -15,11 +15,18 @@ import (
 	"github.com/webhippie/fail"
 	"github.com/webhippie/oauth2-proxy/pkg/config"
 	"github.com/webhippie/oauth2-proxy/pkg/handler"
+	"github.com/webhippie/oauth2-proxy/pkg/jwt"
+	"github.com/webhippie/oauth2-proxy/pkg/middleware/clean"
 	"github.com/webhippie/oauth2-proxy/pkg/middleware/header"
+	"github.com/webhippie/oauth2-proxy/pkg/middleware/realip"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+	"github.com/webhippie/oauth2-proxy/pkg/session"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+	"github.com/webhippie/oauth2-proxy/pkg/upstream"
 )
 
 // Load initializes the routing of the application.
-func Load(cfg *config.Config, proxy http.Handler) http.Handler {
+func Load(cfg *config.Config, sessions *session.Manager, signer *jwt.Signer, skip *policy.Skip, proxy *upstream.Table) http.Handler {
 	mux := chi.NewRouter()
 
 	mux.Use(hlog.NewHandler(log.Logger))
@@ -39,18 +46,28 @@ func Load(cfg *config.Config, proxy http.Handler) http.Handler {
 	}))
 
 	mux.Use(middleware.Timeout(60 * time.Second))
-	mux.Use(middleware.RealIP)
+	mux.Use(realip.Trusted(cfg.Server.TrustedProxies))
+	mux.Use(clean.Path)
 
 	mux.Use(header.Version)
 	mux.Use(header.Cache)
 	mux.Use(header.Secure)
 	mux.Use(header.Options)
 
-	mux.NotFound(handler.Proxy(cfg, proxy))
+	mux.NotFound(handler.Proxy(cfg, sessions, signer, skip, proxy))
 
 	mux.Route(cfg.Server.Root, func(root chi.Router) {
-		root.Get("/login", handler.Login(cfg))
-		root.Post("/login", handler.Auth(cfg))
+		root.Get("/login", handler.Login(cfg, sessions))
+		root.Get("/logout", handler.Logout(cfg, sessions))
+		root.Post("/logout", handler.SignOut(cfg, sessions))
+		root.HandleFunc("/auth", handler.AuthRequest(cfg, sessions, signer, proxy))
+
+		root.Post("/{provider}/auth", handler.Authorize(cfg, sessions))
+		root.Get("/{provider}/callback", handler.Callback(cfg, sessions))
+
+		if signer != nil {
+			root.Get("/jwks.json", handler.JWKS(cfg, signer))
+		}
 
 		root.Handle("/assets/*", handler.Static(cfg))
 	})
@@ -59,7 +76,7 @@ func Load(cfg *config.Config, proxy http.Handler) http.Handler {
 }
 
 // Status initializes the routing of metrics and healtchecks.
-func Status(cfg *config.Config) http.Handler {
+func Status(cfg *config.Config, storage store.SessionStore, proxy *upstream.Table) http.Handler {
 	mux := chi.NewRouter()
 
 	mux.Use(hlog.NewHandler(log.Logger))
@@ -69,7 +86,7 @@ func Status(cfg *config.Config) http.Handler {
 	mux.Use(hlog.RequestIDHandler("request_id", "Request-Id"))
 
 	mux.Use(middleware.Timeout(60 * time.Second))
-	mux.Use(middleware.RealIP)
+	mux.Use(realip.Trusted(cfg.Server.TrustedProxies))
 
 	mux.Use(header.Version)
 	mux.Use(header.Cache)
@@ -86,12 +103,7 @@ func Status(cfg *config.Config) http.Handler {
 			io.WriteString(w, http.StatusText(http.StatusOK))
 		})
 
-		root.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusOK)
-
-			io.WriteString(w, http.StatusText(http.StatusOK))
-		})
+		root.Get("/readyz", handler.Ready(cfg, storage, proxy))
 	})
 
 	return mux
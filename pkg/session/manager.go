@@ -0,0 +1,302 @@
+package session
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/rs/zerolog/log"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/metrics"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+)
+
+var (
+	// ErrMissing gets returned if the request doesn't contain a session.
+	ErrMissing = errors.New("session cookie is missing")
+
+	// ErrExpired gets returned if the session lifetime has been exceeded.
+	ErrExpired = errors.New("session is expired")
+
+	// ErrTooLarge gets returned if the session doesn't fit into a cookie.
+	ErrTooLarge = errors.New("session is too large for the cookie store, use the memory, file or redis store")
+)
+
+const (
+	// flowLifetime defines how long values of the login flow stay valid.
+	flowLifetime = 10 * time.Minute
+
+	// cookieLimit defines the maximum size of a session stored in a cookie.
+	cookieLimit = 4096
+)
+
+// Manager handles the encrypted and signed sessions, the encoded sessions
+// get persisted within the configured session store.
+type Manager struct {
+	cfg      *config.Config
+	store    store.SessionStore
+	codec    *securecookie.SecureCookie
+	flow     *securecookie.SecureCookie
+	hashKey  []byte
+	blockKey []byte
+}
+
+// New prepares a new session manager.
+func New(cfg *config.Config, store store.SessionStore) *Manager {
+	var (
+		hashKey  []byte
+		blockKey []byte
+	)
+
+	if cfg.Session.Secret == "" {
+		log.Warn().
+			Msg("no session secret defined, sessions will not survive a restart")
+
+		hashKey = securecookie.GenerateRandomKey(64)
+		blockKey = securecookie.GenerateRandomKey(32)
+	} else {
+		hash := sha512.Sum512([]byte("hash:" + cfg.Session.Secret))
+		block := sha256.Sum256([]byte("block:" + cfg.Session.Secret))
+
+		hashKey = hash[:]
+		blockKey = block[:]
+	}
+
+	codec := securecookie.New(
+		hashKey,
+		blockKey,
+	)
+
+	codec.MaxAge(
+		int(cfg.Session.Lifetime / time.Second),
+	)
+
+	// server-side stores are not limited by the cookie size
+	codec.MaxLength(0)
+
+	flow := securecookie.New(
+		hashKey,
+		blockKey,
+	)
+
+	flow.MaxAge(
+		int(flowLifetime / time.Second),
+	)
+
+	return &Manager{
+		cfg:      cfg,
+		store:    store,
+		codec:    codec,
+		flow:     flow,
+		hashKey:  hashKey,
+		blockKey: blockKey,
+	}
+}
+
+// Load reads and validates the session referenced by the request cookie.
+func (m *Manager) Load(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(m.cfg.Session.Name)
+
+	if err != nil {
+		return nil, ErrMissing
+	}
+
+	data, err := m.store.Load(cookie.Value)
+
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, ErrMissing
+		}
+
+		return nil, err
+	}
+
+	s := &Session{}
+
+	if err := m.codec.Decode(m.cfg.Session.Name, data, s); err != nil {
+		return nil, err
+	}
+
+	if s.Expired() {
+		metrics.SessionsExpired.Inc()
+		return nil, ErrExpired
+	}
+
+	return s, nil
+}
+
+// Save persists the session and issues the session cookie. New sessions
+// always get a fresh reference, the previous one gets deleted to prevent
+// session fixation.
+func (m *Manager) Save(w http.ResponseWriter, r *http.Request, s *Session) error {
+	created := s.CreatedAt.IsZero()
+
+	if created {
+		s.CreatedAt = time.Now().UTC()
+	}
+
+	if s.ExpiresAt.IsZero() {
+		s.ExpiresAt = s.CreatedAt.Add(m.cfg.Session.Lifetime)
+	}
+
+	data, err := m.codec.Encode(m.cfg.Session.Name, s)
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := m.store.(*store.Cookie); ok && len(data) > cookieLimit {
+		return ErrTooLarge
+	}
+
+	ref := ""
+
+	if cookie, err := r.Cookie(m.cfg.Session.Name); err == nil {
+		if created {
+			if err := m.store.Delete(cookie.Value); err != nil {
+				log.Warn().
+					Err(err).
+					Msg("failed to delete previous session from store")
+			}
+		} else {
+			ref = cookie.Value
+		}
+	}
+
+	value, err := m.store.Save(ref, data, time.Until(s.ExpiresAt))
+
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, m.cookie(value, s.ExpiresAt))
+
+	if created {
+		metrics.SessionsCreated.Inc()
+	}
+
+	return nil
+}
+
+// Clear removes the session from the store and the cookie from the browser.
+func (m *Manager) Clear(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(m.cfg.Session.Name); err == nil {
+		if err := m.store.Delete(cookie.Value); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("failed to delete session from store")
+		}
+	}
+
+	cookie := m.cookie("", time.Unix(0, 0))
+	cookie.MaxAge = -1
+
+	http.SetCookie(w, cookie)
+}
+
+// SetValue stores a short-lived value required during the login flow.
+func (m *Manager) SetValue(w http.ResponseWriter, key string, value interface{}) error {
+	name := m.flowName(key)
+	encoded, err := m.flow.Encode(name, value)
+
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, m.flowCookie(name, encoded, time.Now().Add(flowLifetime)))
+	return nil
+}
+
+// GetValue reads a short-lived value stored during the login flow.
+func (m *Manager) GetValue(r *http.Request, key string, value interface{}) error {
+	name := m.flowName(key)
+	cookie, err := r.Cookie(name)
+
+	if err != nil {
+		return ErrMissing
+	}
+
+	return m.flow.Decode(name, cookie.Value, value)
+}
+
+// DelValue removes a short-lived value stored during the login flow.
+func (m *Manager) DelValue(w http.ResponseWriter, key string) {
+	cookie := m.flowCookie(m.flowName(key), "", time.Unix(0, 0))
+	cookie.MaxAge = -1
+
+	http.SetCookie(w, cookie)
+}
+
+// Store returns a session store used by gothic during the login flow.
+func (m *Manager) Store() sessions.Store {
+	store := sessions.NewCookieStore(
+		m.hashKey,
+		m.blockKey,
+	)
+
+	store.Options = &sessions.Options{
+		Path:     m.flowPath(),
+		Domain:   m.cfg.Session.Domain,
+		MaxAge:   int(flowLifetime / time.Second),
+		Secure:   m.cfg.Session.Secure,
+		HttpOnly: true,
+	}
+
+	return store
+}
+
+func (m *Manager) flowName(key string) string {
+	return m.cfg.Session.Name + "_" + key
+}
+
+func (m *Manager) flowPath() string {
+	if m.cfg.Server.Root == "" {
+		return "/"
+	}
+
+	return m.cfg.Server.Root
+}
+
+func (m *Manager) flowCookie(name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   m.cfg.Session.Domain,
+		Path:     m.flowPath(),
+		Expires:  expires,
+		Secure:   m.cfg.Session.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (m *Manager) cookie(value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.cfg.Session.Name,
+		Value:    value,
+		Domain:   m.cfg.Session.Domain,
+		Path:     m.cfg.Session.Path,
+		Expires:  expires,
+		Secure:   m.cfg.Session.Secure,
+		HttpOnly: m.cfg.Session.HTTPOnly,
+		SameSite: sameSite(m.cfg.Session.SameSite),
+	}
+}
+
+func sameSite(val string) http.SameSite {
+	switch strings.ToLower(val) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
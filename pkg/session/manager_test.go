@@ -0,0 +1,185 @@
+package session
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+)
+
+func testManager(st store.SessionStore) *Manager {
+	cfg := config.New()
+	cfg.Session.Secret = "secret"
+	cfg.Session.Name = "_session"
+	cfg.Session.Lifetime = time.Hour
+
+	return New(cfg, st)
+}
+
+func withCookies(w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+
+	return r
+}
+
+func TestManager(t *testing.T) {
+	tests := []struct {
+		name  string
+		store store.SessionStore
+	}{
+		{"cookie", store.NewCookie()},
+		{"memory", store.NewMemory(10, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testManager(tt.store)
+			w := httptest.NewRecorder()
+
+			if err := m.Save(w, httptest.NewRequest("GET", "/", nil), &Session{Username: "jdoe"}); err != nil {
+				t.Fatalf("failed to save session: %s", err)
+			}
+
+			r := withCookies(w)
+			s, err := m.Load(r)
+
+			if err != nil || s.Username != "jdoe" {
+				t.Fatalf("expected session of jdoe, got %v (%v)", s, err)
+			}
+
+			if _, err := testManager(tt.store).Load(r); err != nil {
+				t.Errorf("expected session to decode with the same secret, got %s", err)
+			}
+
+			w = httptest.NewRecorder()
+			m.Clear(w, r)
+
+			if cookie := w.Result().Cookies()[0]; cookie.MaxAge >= 0 {
+				t.Errorf("expected cookie removal, got %v", cookie)
+			}
+		})
+	}
+}
+
+func TestManagerExpired(t *testing.T) {
+	m := testManager(store.NewCookie())
+	w := httptest.NewRecorder()
+
+	s := &Session{
+		Username:  "jdoe",
+		CreatedAt: time.Now().Add(-2 * time.Hour),
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}
+
+	if err := m.Save(w, httptest.NewRequest("GET", "/", nil), s); err != nil {
+		t.Fatalf("failed to save session: %s", err)
+	}
+
+	if _, err := m.Load(withCookies(w)); err != ErrExpired {
+		t.Errorf("expected expired session, got %v", err)
+	}
+}
+
+func TestManagerLarge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+
+	if err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	file, err := store.NewFile(dir, 0)
+
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+
+	defer file.Close()
+
+	orgs := make([]string, 150)
+
+	for i := range orgs {
+		orgs[i] = fmt.Sprintf("organization-%03d/team-%03d", i, i)
+	}
+
+	tests := []struct {
+		name  string
+		store store.SessionStore
+		err   error
+	}{
+		{"cookie", store.NewCookie(), ErrTooLarge},
+		{"memory", store.NewMemory(10, 0), nil},
+		{"file", file, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testManager(tt.store)
+			w := httptest.NewRecorder()
+
+			if err := m.Save(w, httptest.NewRequest("GET", "/", nil), &Session{Username: "jdoe", Orgs: orgs}); err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			s, err := m.Load(withCookies(w))
+
+			if err != nil || len(s.Orgs) != len(orgs) {
+				t.Errorf("expected session with %d orgs, got %v", len(orgs), err)
+			}
+		})
+	}
+}
+
+func TestManagerFixation(t *testing.T) {
+	st := store.NewMemory(10, 0)
+	m := testManager(st)
+
+	// the attacker obtains a valid reference and plants it in the browser
+	w := httptest.NewRecorder()
+	m.Save(w, httptest.NewRequest("GET", "/", nil), &Session{Username: "attacker"})
+	planted := withCookies(w)
+
+	w = httptest.NewRecorder()
+
+	if err := m.Save(w, planted, &Session{Username: "victim"}); err != nil {
+		t.Fatalf("failed to save session: %s", err)
+	}
+
+	if _, err := m.Load(planted); err != ErrMissing {
+		t.Errorf("expected planted reference to be deleted, got %v", err)
+	}
+
+	s, err := m.Load(withCookies(w))
+
+	if err != nil || s.Username != "victim" {
+		t.Errorf("expected fresh session of victim, got %v (%v)", s, err)
+	}
+
+	// updates of an existing session keep the reference
+	existing := withCookies(w)
+	w = httptest.NewRecorder()
+	s.Orgs = []string{"admins"}
+
+	if err := m.Save(w, existing, s); err != nil {
+		t.Fatalf("failed to update session: %s", err)
+	}
+
+	if w.Result().Cookies()[0].Value != existing.Cookies()[0].Value {
+		t.Error("expected update to keep the session reference")
+	}
+}
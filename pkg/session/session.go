@@ -0,0 +1,29 @@
+package session
+
+import (
+	"time"
+)
+
+// Session defines the identity of an authenticated user.
+type Session struct {
+	Provider      string
+	UserID        string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Orgs          []string
+	Claims        map[string][]string
+	AccessToken   string
+	RefreshToken  string
+	TokenExpiry   time.Time
+	IDToken       string
+	ValidatedAt   time.Time
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+// Expired checks if the session lifetime has been exceeded.
+func (s *Session) Expired() bool {
+	return !s.ExpiresAt.IsZero() && time.Now().After(s.ExpiresAt)
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrState gets returned if the OAuth2 state is invalid, expired or not
+	// bound to the browser.
+	ErrState = errors.New("state is invalid or expired")
+
+	// ErrCSRF gets returned if the submitted CSRF token doesn't match.
+	ErrCSRF = errors.New("csrf token is invalid")
+)
+
+// State defines the signed OAuth2 state, the nonce gets additionally stored
+// within a cookie to bind the state to the browser.
+type State struct {
+	Nonce    string
+	Provider string
+	Redirect string
+}
+
+// NewState issues a signed and time-limited state for the login flow.
+func (m *Manager) NewState(w http.ResponseWriter, provider, redirect string) (string, error) {
+	nonce, err := random()
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.SetValue(w, "state", nonce); err != nil {
+		return "", err
+	}
+
+	return m.flow.Encode(m.flowName("state"), State{
+		Nonce:    nonce,
+		Provider: provider,
+		Redirect: redirect,
+	})
+}
+
+// VerifyState decodes the state and compares it with the nonce stored in
+// the browser, the nonce can only be used once.
+func (m *Manager) VerifyState(w http.ResponseWriter, r *http.Request, provider, raw string) (*State, error) {
+	nonce := ""
+
+	if err := m.GetValue(r, "state", &nonce); err != nil {
+		return nil, ErrState
+	}
+
+	m.DelValue(w, "state")
+	s := &State{}
+
+	if err := m.flow.Decode(m.flowName("state"), raw, s); err != nil {
+		return nil, ErrState
+	}
+
+	if subtle.ConstantTimeCompare([]byte(nonce), []byte(s.Nonce)) != 1 || s.Provider != provider {
+		return nil, ErrState
+	}
+
+	return s, nil
+}
+
+// CSRF returns the CSRF token of the browser and extends its lifetime, a
+// new token gets issued if the browser doesn't have a valid one.
+func (m *Manager) CSRF(w http.ResponseWriter, r *http.Request) (string, error) {
+	token := ""
+
+	if err := m.GetValue(r, "csrf", &token); err != nil || token == "" {
+		generated, err := random()
+
+		if err != nil {
+			return "", err
+		}
+
+		token = generated
+	}
+
+	if err := m.SetValue(w, "csrf", token); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// VerifyCSRF compares the submitted token with the token of the browser.
+func (m *Manager) VerifyCSRF(r *http.Request, submitted string) error {
+	token := ""
+
+	if err := m.GetValue(r, "csrf", &token); err != nil || token == "" {
+		return ErrCSRF
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(submitted)) != 1 {
+		return ErrCSRF
+	}
+
+	return nil
+}
+
+func random() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,186 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/store"
+)
+
+// issueState issues a state and returns it together with the browser request
+// carrying the nonce cookie.
+func issueState(t *testing.T, m *Manager, provider string) (string, *http.Request) {
+	w := httptest.NewRecorder()
+	state, err := m.NewState(w, provider, "/wiki")
+
+	if err != nil {
+		t.Fatalf("failed to issue state: %s", err)
+	}
+
+	return state, withCookies(w)
+}
+
+func TestState(t *testing.T) {
+	m := testManager(store.NewCookie())
+
+	tests := []struct {
+		name     string
+		prepare  func(t *testing.T) (string, *http.Request)
+		provider string
+		valid    bool
+	}{
+		{
+			name: "valid",
+			prepare: func(t *testing.T) (string, *http.Request) {
+				return issueState(t, m, "github")
+			},
+			provider: "github",
+			valid:    true,
+		},
+		{
+			name: "cross provider",
+			prepare: func(t *testing.T) (string, *http.Request) {
+				return issueState(t, m, "github")
+			},
+			provider: "gitlab",
+		},
+		{
+			name: "tampered",
+			prepare: func(t *testing.T) (string, *http.Request) {
+				state, r := issueState(t, m, "github")
+				b := []byte(state)
+
+				if i := len(b) / 2; b[i] == 'A' {
+					b[i] = 'B'
+				} else {
+					b[i] = 'A'
+				}
+
+				return string(b), r
+			},
+			provider: "github",
+		},
+		{
+			name: "other browser",
+			prepare: func(t *testing.T) (string, *http.Request) {
+				state, _ := issueState(t, m, "github")
+				return state, httptest.NewRequest("GET", "/", nil)
+			},
+			provider: "github",
+		},
+		{
+			name: "previous login",
+			prepare: func(t *testing.T) (string, *http.Request) {
+				state, _ := issueState(t, m, "github")
+				_, r := issueState(t, m, "github")
+
+				return state, r
+			},
+			provider: "github",
+		},
+		{
+			name: "other secret",
+			prepare: func(t *testing.T) (string, *http.Request) {
+				cfg := *m.cfg
+				cfg.Session.Secret = "other"
+
+				return issueState(t, New(&cfg, store.NewCookie()), "github")
+			},
+			provider: "github",
+		},
+		{
+			name: "replayed",
+			prepare: func(t *testing.T) (string, *http.Request) {
+				state, r := issueState(t, m, "github")
+				w := httptest.NewRecorder()
+
+				if _, err := m.VerifyState(w, r, "github", state); err != nil {
+					t.Fatalf("failed to verify state: %s", err)
+				}
+
+				return state, withCookies(w)
+			},
+			provider: "github",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, r := tt.prepare(t)
+			w := httptest.NewRecorder()
+
+			s, err := m.VerifyState(w, r, tt.provider, raw)
+
+			if !tt.valid {
+				if err != ErrState {
+					t.Errorf("expected %v, got %v", ErrState, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected valid state, got %s", err)
+			}
+
+			if s.Provider != "github" || s.Redirect != "/wiki" {
+				t.Errorf("expected state for github and /wiki, got %+v", s)
+			}
+
+			if cookie := w.Result().Cookies(); len(cookie) != 1 || cookie[0].MaxAge >= 0 {
+				t.Errorf("expected nonce cookie removal, got %v", cookie)
+			}
+		})
+	}
+}
+
+func TestStateExpired(t *testing.T) {
+	m := testManager(store.NewCookie())
+	m.flow.MaxAge(1)
+
+	state, r := issueState(t, m, "github")
+	time.Sleep(2 * time.Second)
+
+	if _, err := m.VerifyState(httptest.NewRecorder(), r, "github", state); err != ErrState {
+		t.Errorf("expected %v, got %v", ErrState, err)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	m := testManager(store.NewCookie())
+	w := httptest.NewRecorder()
+
+	token, err := m.CSRF(w, httptest.NewRequest("GET", "/", nil))
+
+	if err != nil || token == "" {
+		t.Fatalf("failed to issue csrf token: %v", err)
+	}
+
+	r := withCookies(w)
+
+	if again, err := m.CSRF(httptest.NewRecorder(), r); err != nil || again != token {
+		t.Errorf("expected token %q to be kept, got %q", token, again)
+	}
+
+	tests := []struct {
+		name      string
+		request   *http.Request
+		submitted string
+		err       error
+	}{
+		{"valid", r, token, nil},
+		{"missing", r, "", ErrCSRF},
+		{"mismatched", r, token + "x", ErrCSRF},
+		{"without cookie", httptest.NewRequest("GET", "/", nil), token, ErrCSRF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.VerifyCSRF(tt.request, tt.submitted); err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
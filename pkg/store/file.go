@@ -0,0 +1,206 @@
+package store
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// File stores the sessions as files within the storage path.
+type File struct {
+	dir  string
+	done chan struct{}
+}
+
+// NewFile prepares a new file-backed session store.
+func NewFile(storage string, sweep time.Duration) (*File, error) {
+	dir := path.Join(storage, "sessions")
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	s := &File{
+		dir:  dir,
+		done: make(chan struct{}),
+	}
+
+	go sweeper(sweep, s.done, s.sweep)
+	return s, nil
+}
+
+// Load reads the referenced session file.
+func (s *File) Load(ref string) (string, error) {
+	if !validTicket(ref) {
+		return "", ErrNotFound
+	}
+
+	expires, data, err := s.read(ref)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+
+		return "", err
+	}
+
+	if time.Now().After(expires) {
+		os.Remove(s.file(ref))
+		return "", ErrNotFound
+	}
+
+	return data, nil
+}
+
+// Save writes the session file, an existing file gets replaced. Unknown
+// references get replaced by a fresh ticket.
+func (s *File) Save(ref, data string, ttl time.Duration) (string, error) {
+	if !s.exists(ref) {
+		var err error
+
+		if ref, err = ticket(); err != nil {
+			return "", err
+		}
+	}
+
+	content := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10) + "\n" + data
+	tmp := s.file(ref) + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, []byte(content), 0600); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp, s.file(ref)); err != nil {
+		return "", err
+	}
+
+	return ref, nil
+}
+
+// Delete removes the referenced session file.
+func (s *File) Delete(ref string) error {
+	if !validTicket(ref) {
+		return nil
+	}
+
+	if err := os.Remove(s.file(ref)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Count returns the number of stored session files.
+func (s *File) Count() (int, error) {
+	files, err := ioutil.ReadDir(s.dir)
+
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, f := range files {
+		if !f.IsDir() && validTicket(f.Name()) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Ping verifies that the storage directory is writable.
+func (s *File) Ping() error {
+	file, err := ioutil.TempFile(s.dir, ".ping")
+
+	if err != nil {
+		return err
+	}
+
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// Close stops the expiry sweeper.
+func (s *File) Close() error {
+	close(s.done)
+	return nil
+}
+
+func (s *File) sweep() {
+	files, err := ioutil.ReadDir(s.dir)
+
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("dir", s.dir).
+			Msg("failed to list session files")
+
+		return
+	}
+
+	now := time.Now()
+
+	for _, f := range files {
+		if f.IsDir() || !validTicket(f.Name()) {
+			continue
+		}
+
+		expires, _, err := s.read(f.Name())
+
+		if err != nil || now.After(expires) {
+			os.Remove(s.file(f.Name()))
+		}
+	}
+}
+
+func (s *File) exists(ref string) bool {
+	if !validTicket(ref) {
+		return false
+	}
+
+	_, err := os.Stat(s.file(ref))
+	return err == nil
+}
+
+func (s *File) read(ref string) (time.Time, string, error) {
+	f, err := os.Open(s.file(ref))
+
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	line, err := reader.ReadString('\n')
+
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	unix, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
+
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	data, err := ioutil.ReadAll(reader)
+
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	return time.Unix(unix, 0), string(data), nil
+}
+
+func (s *File) file(ref string) string {
+	return path.Join(s.dir, ref)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+
+	if err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	s, err := NewFile(dir, 0)
+
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+
+	defer s.Close()
+	testStore(t, s)
+
+	if count, err := s.Count(); err != nil || count != 2 {
+		t.Errorf("expected two sessions, got %d (%v)", count, err)
+	}
+}
+
+func TestFileExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+
+	if err != nil {
+		t.Fatalf("failed to create dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	s, err := NewFile(dir, time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+
+	defer s.Close()
+
+	ref, _ := s.Save("", "expired", -time.Second)
+
+	if _, err := s.Load(ref); err != ErrNotFound {
+		t.Errorf("expected expired session to be missing, got %v", err)
+	}
+
+	s.Save("", "expired", -time.Second)
+	time.Sleep(20 * time.Millisecond)
+
+	if count, _ := s.Count(); count != 0 {
+		t.Errorf("expected expired sessions to be swept, got %d", count)
+	}
+}
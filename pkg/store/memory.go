@@ -0,0 +1,161 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	ref     string
+	data    string
+	expires time.Time
+}
+
+// Memory stores the sessions within a size limited LRU cache.
+type Memory struct {
+	size    int
+	mutex   sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	done    chan struct{}
+}
+
+// NewMemory prepares a new in-memory session store.
+func NewMemory(size int, sweep time.Duration) *Memory {
+	s := &Memory{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		done:    make(chan struct{}),
+	}
+
+	go sweeper(sweep, s.done, s.sweep)
+	return s
+}
+
+// Load returns the referenced session and marks it as recently used.
+func (s *Memory) Load(ref string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.entries[ref]
+
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	entry := elem.Value.(*memoryEntry)
+
+	if time.Now().After(entry.expires) {
+		s.remove(elem)
+		return "", ErrNotFound
+	}
+
+	s.order.MoveToFront(elem)
+	return entry.data, nil
+}
+
+// Save stores the session and evicts the least recently used sessions.
+func (s *Memory) Save(ref, data string, ttl time.Duration) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if elem, ok := s.entries[ref]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.data = data
+		entry.expires = time.Now().Add(ttl)
+
+		s.order.MoveToFront(elem)
+		return ref, nil
+	}
+
+	ref, err := ticket()
+
+	if err != nil {
+		return "", err
+	}
+
+	s.entries[ref] = s.order.PushFront(&memoryEntry{
+		ref:     ref,
+		data:    data,
+		expires: time.Now().Add(ttl),
+	})
+
+	for s.size > 0 && s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+
+	return ref, nil
+}
+
+// Delete removes the referenced session.
+func (s *Memory) Delete(ref string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if elem, ok := s.entries[ref]; ok {
+		s.remove(elem)
+	}
+
+	return nil
+}
+
+// Count returns the number of stored sessions.
+func (s *Memory) Count() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.order.Len(), nil
+}
+
+// Ping always succeeds as the sessions are kept in memory.
+func (s *Memory) Ping() error {
+	return nil
+}
+
+// Close stops the expiry sweeper.
+func (s *Memory) Close() error {
+	close(s.done)
+	return nil
+}
+
+func (s *Memory) sweep() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+
+	for elem := s.order.Back(); elem != nil; {
+		prev := elem.Prev()
+
+		if now.After(elem.Value.(*memoryEntry).expires) {
+			s.remove(elem)
+		}
+
+		elem = prev
+	}
+}
+
+func (s *Memory) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*memoryEntry).ref)
+}
+
+func sweeper(interval time.Duration, done chan struct{}, sweep func()) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sweep()
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	s := NewMemory(10, 0)
+	defer s.Close()
+
+	testStore(t, s)
+}
+
+func TestMemoryEviction(t *testing.T) {
+	s := NewMemory(2, 0)
+	defer s.Close()
+
+	first, _ := s.Save("", "first", time.Minute)
+	second, _ := s.Save("", "second", time.Minute)
+
+	s.Load(first)
+	s.Save("", "third", time.Minute)
+
+	if _, err := s.Load(second); err != ErrNotFound {
+		t.Errorf("expected least recently used session to be evicted, got %v", err)
+	}
+
+	if data, err := s.Load(first); err != nil || data != "first" {
+		t.Errorf("expected recently used session, got %q (%v)", data, err)
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	s := NewMemory(10, time.Millisecond)
+	defer s.Close()
+
+	ref, _ := s.Save("", "expiring", time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	if count, _ := s.Count(); count != 0 {
+		t.Errorf("expected expired session to be swept, got %d", count)
+	}
+
+	if _, err := s.Load(ref); err != ErrNotFound {
+		t.Errorf("expected expired session to be missing, got %v", err)
+	}
+}
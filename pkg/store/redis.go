@@ -0,0 +1,336 @@
+package store
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// redisPrefix defines the prefix for all session keys.
+	redisPrefix = "oauth2-proxy:session:"
+
+	// redisPool defines the number of idle connections to keep.
+	redisPool = 8
+
+	// redisTimeout defines the timeout for dialing and commands.
+	redisTimeout = 5 * time.Second
+)
+
+// Redis stores the sessions on a server speaking the Redis protocol.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	mutex    sync.Mutex
+	idle     []*redisConn
+}
+
+// NewRedis prepares a new Redis-protocol session store.
+func NewRedis(addr, password string, db int) (*Redis, error) {
+	s := &Redis{
+		addr:     addr,
+		password: password,
+		db:       db,
+	}
+
+	if _, err := s.do("PING"); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Load fetches the referenced session.
+func (s *Redis) Load(ref string) (string, error) {
+	if !validTicket(ref) {
+		return "", ErrNotFound
+	}
+
+	val, err := s.do("GET", redisPrefix+ref)
+
+	if err != nil {
+		return "", err
+	}
+
+	if val == nil {
+		return "", ErrNotFound
+	}
+
+	return val.(string), nil
+}
+
+// Save stores the session with an expiry, an existing key gets replaced.
+// Unknown references get replaced by a fresh ticket.
+func (s *Redis) Save(ref, data string, ttl time.Duration) (string, error) {
+	seconds := int64(ttl / time.Second)
+
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	expiry := strconv.FormatInt(seconds, 10)
+
+	if validTicket(ref) {
+		val, err := s.do("SET", redisPrefix+ref, data, "EX", expiry, "XX")
+
+		if err != nil {
+			return "", err
+		}
+
+		if val != nil {
+			return ref, nil
+		}
+	}
+
+	ref, err := ticket()
+
+	if err != nil {
+		return "", err
+	}
+
+	val, err := s.do("SET", redisPrefix+ref, data, "EX", expiry, "NX")
+
+	if err != nil {
+		return "", err
+	}
+
+	if val == nil {
+		return "", errors.New("session reference already exists")
+	}
+
+	return ref, nil
+}
+
+// Delete removes the referenced session.
+func (s *Redis) Delete(ref string) error {
+	if !validTicket(ref) {
+		return nil
+	}
+
+	_, err := s.do("DEL", redisPrefix+ref)
+	return err
+}
+
+// Count scans for all session keys, the server drops expired keys itself.
+func (s *Redis) Count() (int, error) {
+	cursor, count := "0", 0
+
+	for {
+		val, err := s.do("SCAN", cursor, "MATCH", redisPrefix+"*", "COUNT", "1000")
+
+		if err != nil {
+			return 0, err
+		}
+
+		reply, ok := val.([]interface{})
+
+		if !ok || len(reply) != 2 {
+			return 0, fmt.Errorf("unexpected scan reply %v", val)
+		}
+
+		next, ok := reply[0].(string)
+
+		if !ok {
+			return 0, fmt.Errorf("unexpected scan cursor %v", reply[0])
+		}
+
+		keys, _ := reply[1].([]interface{})
+		count += len(keys)
+
+		if next == "0" {
+			return count, nil
+		}
+
+		cursor = next
+	}
+}
+
+// Ping sends a PING command to the server.
+func (s *Redis) Ping() error {
+	_, err := s.do("PING")
+	return err
+}
+
+// Close closes all idle connections.
+func (s *Redis) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, conn := range s.idle {
+		conn.Close()
+	}
+
+	s.idle = nil
+	return nil
+}
+
+func (s *Redis) do(args ...string) (interface{}, error) {
+	conn, err := s.get()
+
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := conn.do(args...)
+
+	if err != nil {
+		var reply redisError
+
+		if !errors.As(err, &reply) {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	s.put(conn)
+	return val, err
+}
+
+func (s *Redis) get() (*redisConn, error) {
+	s.mutex.Lock()
+
+	if n := len(s.idle); n > 0 {
+		conn := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mutex.Unlock()
+
+		return conn, nil
+	}
+
+	s.mutex.Unlock()
+
+	conn, err := net.DialTimeout("tcp", s.addr, redisTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &redisConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+
+	if s.password != "" {
+		if _, err := c.do("AUTH", s.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if s.db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(s.db)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (s *Redis) put(conn *redisConn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.idle) >= redisPool {
+		conn.Close()
+		return
+	}
+
+	s.idle = append(s.idle, conn)
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	c.SetDeadline(time.Now().Add(redisTimeout))
+
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+
+	for _, arg := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(arg))+"\r\n"+arg+"\r\n"...)
+	}
+
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return c.read()
+}
+
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed redis reply %q", line)
+	}
+
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if size < 0 {
+			return nil, nil
+		}
+
+		data := make([]byte, size+2)
+
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+
+		return string(data[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if size < 0 {
+			return nil, nil
+		}
+
+		result := make([]interface{}, size)
+
+		for i := range result {
+			if result[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("unexpected redis reply %q", line)
+}
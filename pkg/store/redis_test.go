@@ -0,0 +1,239 @@
+package store
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis implements the subset of the Redis protocol used by the store.
+type fakeRedis struct {
+	listener net.Listener
+	password string
+	mutex    sync.Mutex
+	data     map[string]string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	f := &fakeRedis{
+		listener: listener,
+		password: password,
+		data:     map[string]string{},
+	}
+
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) Addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedis) Close() {
+	f.listener.Close()
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+
+		if err != nil {
+			return
+		}
+
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	authed := f.password == ""
+
+	for {
+		args, err := readCommand(reader)
+
+		if err != nil {
+			return
+		}
+
+		if !authed && strings.ToUpper(args[0]) != "AUTH" {
+			conn.Write([]byte("-NOAUTH Authentication required\r\n"))
+			continue
+		}
+
+		f.mutex.Lock()
+		reply := f.exec(args, &authed)
+		f.mutex.Unlock()
+
+		conn.Write([]byte(reply))
+	}
+}
+
+func (f *fakeRedis) exec(args []string, authed *bool) string {
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[1] != f.password {
+			return "-ERR invalid password\r\n"
+		}
+
+		*authed = true
+		return "+OK\r\n"
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		val, ok := f.data[args[1]]
+
+		if !ok {
+			return "$-1\r\n"
+		}
+
+		return bulk(val)
+	case "SET":
+		_, exists := f.data[args[1]]
+
+		for _, opt := range args[3:] {
+			switch strings.ToUpper(opt) {
+			case "NX":
+				if exists {
+					return "$-1\r\n"
+				}
+			case "XX":
+				if !exists {
+					return "$-1\r\n"
+				}
+			}
+		}
+
+		f.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "DEL":
+		if _, ok := f.data[args[1]]; !ok {
+			return ":0\r\n"
+		}
+
+		delete(f.data, args[1])
+		return ":1\r\n"
+	case "SCAN":
+		prefix := strings.TrimSuffix(args[3], "*")
+		keys := []string{}
+
+		for key := range f.data {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, bulk(key))
+			}
+		}
+
+		return "*2\r\n" + bulk("0") + "*" + strconv.Itoa(len(keys)) + "\r\n" + strings.Join(keys, "")
+	}
+
+	return "-ERR unknown command\r\n"
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		line, err := reader.ReadString('\n')
+
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+
+		args = append(args, string(buf[:size]))
+	}
+
+	return args, nil
+}
+
+func bulk(val string) string {
+	return "$" + strconv.Itoa(len(val)) + "\r\n" + val + "\r\n"
+}
+
+func TestRedisAuth(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"valid", "secret", true},
+		{"invalid", "wrong", false},
+		{"missing", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewRedis(server.Addr(), tt.password, 0)
+
+			if tt.valid && err != nil {
+				t.Fatalf("expected connection, got %s", err)
+			}
+
+			if !tt.valid && err == nil {
+				t.Fatal("expected authentication failure")
+			}
+
+			if s != nil {
+				s.Close()
+			}
+		})
+	}
+}
+
+func TestRedis(t *testing.T) {
+	server := newFakeRedis(t, "")
+	defer server.Close()
+
+	s, err := NewRedis(server.Addr(), "", 0)
+
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+
+	defer s.Close()
+	testStore(t, s)
+
+	if count, err := s.Count(); err != nil || count != 2 {
+		t.Errorf("expected two sessions, got %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+)
+
+var (
+	// ErrNotFound gets returned if the referenced session doesn't exist.
+	ErrNotFound = errors.New("session not found")
+)
+
+// SessionStore defines the interface for session storage backends. The
+// sessions are passed already encrypted and signed, the store returns the
+// reference which gets written into the session cookie.
+type SessionStore interface {
+	// Load resolves the cookie reference into the encoded session.
+	Load(ref string) (string, error)
+
+	// Save persists the encoded session and returns the cookie reference,
+	// only references existing within the store get updated, any other
+	// reference gets replaced by a fresh one.
+	Save(ref, data string, ttl time.Duration) (string, error)
+
+	// Delete removes the referenced session.
+	Delete(ref string) error
+
+	// Ping verifies that the store is reachable.
+	Ping() error
+
+	// Close releases all resources of the store.
+	Close() error
+}
+
+// Counter gets implemented by stores which are able to count the active
+// sessions, sessions stored in cookies can't be counted.
+type Counter interface {
+	// Count returns the number of active sessions.
+	Count() (int, error)
+}
+
+// New initializes the session store based on the configuration.
+func New(cfg *config.Config) (SessionStore, error) {
+	switch cfg.Session.Store {
+	case "", "cookie":
+		return NewCookie(), nil
+	case "memory":
+		return NewMemory(cfg.Session.MemorySize, cfg.Session.Sweep), nil
+	case "file":
+		return NewFile(cfg.Server.Storage, cfg.Session.Sweep)
+	case "redis":
+		return NewRedis(cfg.Session.RedisAddr, cfg.Session.RedisPassword, cfg.Session.RedisDB)
+	}
+
+	return nil, fmt.Errorf("unknown session store %s", cfg.Session.Store)
+}
+
+func ticket() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func validTicket(ref string) bool {
+	if len(ref) != 64 {
+		return false
+	}
+
+	_, err := hex.DecodeString(ref)
+	return err == nil
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStore verifies the behavior shared by all server-side stores.
+func testStore(t *testing.T, s SessionStore) {
+	ref, err := s.Save("", "first\r\nsession", time.Minute)
+
+	if err != nil {
+		t.Fatalf("failed to save session: %s", err)
+	}
+
+	if !validTicket(ref) {
+		t.Fatalf("expected ticket reference, got %q", ref)
+	}
+
+	updated, err := s.Save(ref, "updated", time.Minute)
+
+	if err != nil || updated != ref {
+		t.Fatalf("expected update of %q, got %q (%v)", ref, updated, err)
+	}
+
+	large, err := s.Save("", strings.Repeat("x", 64*1024), time.Minute)
+
+	if err != nil {
+		t.Fatalf("failed to save large session: %s", err)
+	}
+
+	planted := strings.Repeat("ab", 32)
+	fresh, err := s.Save(planted, "victim", time.Minute)
+
+	if err != nil {
+		t.Fatalf("failed to save session: %s", err)
+	}
+
+	if fresh == planted {
+		t.Fatal("unknown reference must not be reused")
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+		data string
+		err  error
+	}{
+		{"existing", ref, "updated", nil},
+		{"fresh", fresh, "victim", nil},
+		{"large", large, strings.Repeat("x", 64*1024), nil},
+		{"planted", planted, "", ErrNotFound},
+		{"traversal", "../../etc/passwd", "", ErrNotFound},
+		{"empty", "", "", ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := s.Load(tt.ref)
+
+			if err != tt.err || data != tt.data {
+				t.Errorf("expected %q (%v), got %q (%v)", tt.data, tt.err, data, err)
+			}
+		})
+	}
+
+	if err := s.Delete(fresh); err != nil {
+		t.Fatalf("failed to delete session: %s", err)
+	}
+
+	if _, err := s.Load(fresh); err != ErrNotFound {
+		t.Errorf("expected deleted session to be missing, got %v", err)
+	}
+
+	if err := s.Ping(); err != nil {
+		t.Errorf("expected ping to succeed, got %s", err)
+	}
+}
+
+func TestCookie(t *testing.T) {
+	s := NewCookie()
+
+	ref, err := s.Save("ignored", "data", time.Minute)
+
+	if err != nil || ref != "data" {
+		t.Fatalf("expected encoded session as reference, got %q (%v)", ref, err)
+	}
+
+	if data, err := s.Load(ref); err != nil || data != "data" {
+		t.Errorf("expected session from reference, got %q (%v)", data, err)
+	}
+
+	if _, err := s.Load(""); err != ErrNotFound {
+		t.Errorf("expected missing session, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package templates
 
 import (
+	"fmt"
 	"html/template"
 	"io/ioutil"
 	"os"
@@ -17,8 +18,32 @@ import (
 
 //go:generate fileb0x ab0x.yaml
 
+var (
+	// required defines the templates which have to be available.
+	required = []string{
+		"login.tmpl",
+		"forbidden.tmpl",
+		"logout.tmpl",
+	}
+)
+
 // Load initializes the template files.
 func Load(cfg *config.Config) *template.Template {
+	tpls, _ := Parse(cfg)
+	return tpls
+}
+
+// Parse initializes the template files, it returns the first failure while
+// all other failures only get logged.
+func Parse(cfg *config.Config) (*template.Template, error) {
+	var failure error
+
+	fail := func(err error) {
+		if failure == nil {
+			failure = err
+		}
+	}
+
 	tpls := template.New(
 		"",
 	).Funcs(
@@ -34,6 +59,8 @@ func Load(cfg *config.Config) *template.Template {
 		log.Warn().
 			Err(err).
 			Msg("failed to get builtin template list")
+
+		fail(err)
 	} else {
 		for _, name := range files {
 			file, readErr := ReadFile(name)
@@ -43,6 +70,8 @@ func Load(cfg *config.Config) *template.Template {
 					Err(readErr).
 					Str("file", name).
 					Msg("failed to read builtin template")
+
+				fail(readErr)
 			}
 
 			_, parseErr := tpls.New(
@@ -56,6 +85,8 @@ func Load(cfg *config.Config) *template.Template {
 					Err(parseErr).
 					Str("file", name).
 					Msg("failed to parse builtin template")
+
+				fail(parseErr)
 			}
 		}
 	}
@@ -85,6 +116,8 @@ func Load(cfg *config.Config) *template.Template {
 						Err(readErr).
 						Str("file", name).
 						Msg("failed to read custom template")
+
+					fail(readErr)
 				}
 
 				_, parseErr := tpls.New(
@@ -104,14 +137,24 @@ func Load(cfg *config.Config) *template.Template {
 						Err(parseErr).
 						Str("file", name).
 						Msg("failed to parse custom template")
+
+					fail(parseErr)
 				}
 			}
 		} else {
 			log.Warn().
 				Str("dir", cfg.Server.Templates).
 				Msg("custom templates directory doesn't exist")
+
+			fail(fmt.Errorf("templates directory %s doesn't exist", cfg.Server.Templates))
 		}
 	}
 
-	return tpls
+	for _, name := range required {
+		if tpls.Lookup(name) == nil {
+			fail(fmt.Errorf("template %s is missing", name))
+		}
+	}
+
+	return tpls, failure
 }
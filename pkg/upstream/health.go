@@ -0,0 +1,311 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+)
+
+// health manages the endpoints of the balancer, endpoints failing the
+// active health checks get removed until they recover.
+type health struct {
+	route    string
+	balancer *roundrobin.RoundRobin
+	check    config.Check
+	targets  map[string]*target
+	stop     chan struct{}
+	mutex    sync.Mutex
+}
+
+type target struct {
+	url       *url.URL
+	healthy   bool
+	successes int
+	failures  int
+}
+
+func newHealth(balancer *roundrobin.RoundRobin) *health {
+	return &health{
+		balancer: balancer,
+		targets:  map[string]*target{},
+	}
+}
+
+// configure reconciles the endpoints with the route and restarts the health
+// checks if their configuration changed.
+func (h *health) configure(route config.Route) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.route = route.Name
+	initial := h.stop == nil
+
+	desired := map[string]*url.URL{}
+
+	for _, endpoint := range endpoints(route) {
+		desired[endpoint.String()] = endpoint
+	}
+
+	added, removed := []string{}, []string{}
+
+	for name, t := range h.targets {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+
+		if t.healthy {
+			h.balancer.RemoveServer(t.url)
+		}
+
+		delete(h.targets, name)
+		removed = append(removed, name)
+	}
+
+	for name, endpoint := range desired {
+		if _, ok := h.targets[name]; ok {
+			continue
+		}
+
+		h.targets[name] = &target{
+			url:     endpoint,
+			healthy: true,
+		}
+
+		h.balancer.UpsertServer(endpoint)
+		added = append(added, name)
+	}
+
+	if h.check != route.Check || h.stop == nil {
+		h.restart(route.Check)
+	}
+
+	if !initial && (len(added) > 0 || len(removed) > 0) {
+		log.Info().
+			Str("route", route.Name).
+			Strs("added", added).
+			Strs("removed", removed).
+			Msg("updated upstream endpoints")
+	}
+}
+
+// close stops the health checks.
+func (h *health) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.stop != nil {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// healthy returns the number of healthy endpoints.
+func (h *health) healthy() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	count := 0
+
+	for _, t := range h.targets {
+		if t.healthy {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (h *health) restart(check config.Check) {
+	if h.stop != nil {
+		close(h.stop)
+	}
+
+	h.check = check
+	h.stop = make(chan struct{})
+
+	if check.Path == "" {
+		for _, t := range h.targets {
+			if !t.healthy {
+				t.healthy = true
+				t.successes, t.failures = 0, 0
+
+				h.balancer.UpsertServer(t.url)
+			}
+		}
+
+		return
+	}
+
+	go h.run(check, h.stop)
+}
+
+func (h *health) run(check config.Check, stop chan struct{}) {
+	ticker := time.NewTicker(check.Interval)
+	defer ticker.Stop()
+
+	client := &http.Client{
+		Timeout: check.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for {
+		h.probe(client, check)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *health) probe(client *http.Client, check config.Check) {
+	h.mutex.Lock()
+	urls := make([]*url.URL, 0, len(h.targets))
+
+	for _, t := range h.targets {
+		urls = append(urls, t.url)
+	}
+
+	h.mutex.Unlock()
+
+	results := make([]error, len(urls))
+	wg := sync.WaitGroup{}
+
+	for i, endpoint := range urls {
+		wg.Add(1)
+
+		go func(i int, endpoint *url.URL) {
+			defer wg.Done()
+			results[i] = request(client, endpoint, check.Path)
+		}(i, endpoint)
+	}
+
+	wg.Wait()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, endpoint := range urls {
+		t, ok := h.targets[endpoint.String()]
+
+		if !ok {
+			continue
+		}
+
+		if results[i] == nil {
+			t.successes++
+			t.failures = 0
+
+			if !t.healthy && t.successes >= check.Healthy {
+				t.healthy = true
+				h.balancer.UpsertServer(t.url)
+
+				log.Info().
+					Str("route", h.route).
+					Str("endpoint", endpoint.String()).
+					Msg("upstream endpoint recovered, adding to balancer")
+			}
+
+			continue
+		}
+
+		t.failures++
+		t.successes = 0
+
+		log.Debug().
+			Err(results[i]).
+			Str("route", h.route).
+			Str("endpoint", endpoint.String()).
+			Msg("upstream health check failed")
+
+		if t.healthy && t.failures >= check.Unhealthy {
+			t.healthy = false
+			h.balancer.RemoveServer(t.url)
+
+			log.Warn().
+				Err(results[i]).
+				Str("route", h.route).
+				Str("endpoint", endpoint.String()).
+				Msg("upstream endpoint unhealthy, removing from balancer")
+		}
+	}
+}
+
+func request(client *http.Client, endpoint *url.URL, path string) error {
+	ref, err := url.Parse(path)
+
+	if err != nil {
+		return err
+	}
+
+	target := *endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(ref.Path, "/")
+	target.RawPath = ""
+	target.RawQuery = ref.RawQuery
+
+	resp, err := client.Get(target.String())
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// checkDefaults fills the unset values of the route checks with the global
+// checks and afterwards with static defaults.
+func checkDefaults(check, global config.Check) config.Check {
+	if check.Path == "" {
+		check.Path = global.Path
+	}
+
+	if check.Interval <= 0 {
+		check.Interval = global.Interval
+	}
+
+	if check.Interval <= 0 {
+		check.Interval = 10 * time.Second
+	}
+
+	if check.Timeout <= 0 {
+		check.Timeout = global.Timeout
+	}
+
+	if check.Timeout <= 0 {
+		check.Timeout = 2 * time.Second
+	}
+
+	if check.Healthy <= 0 {
+		check.Healthy = global.Healthy
+	}
+
+	if check.Healthy <= 0 {
+		check.Healthy = 2
+	}
+
+	if check.Unhealthy <= 0 {
+		check.Unhealthy = global.Unhealthy
+	}
+
+	if check.Unhealthy <= 0 {
+		check.Unhealthy = 3
+	}
+
+	return check
+}
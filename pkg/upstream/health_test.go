@@ -0,0 +1,108 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+)
+
+func TestHealthThresholds(t *testing.T) {
+	var status int32 = http.StatusOK
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+
+	defer srv.Close()
+
+	endpoint, _ := url.Parse(srv.URL)
+	balancer, err := roundrobin.New(http.NotFoundHandler())
+
+	if err != nil {
+		t.Fatalf("failed to create balancer: %s", err)
+	}
+
+	balancer.UpsertServer(endpoint)
+
+	h := newHealth(balancer)
+	h.route = "default"
+	h.targets[endpoint.String()] = &target{url: endpoint, healthy: true}
+
+	check := checkDefaults(config.Check{Path: "/healthz"}, config.Check{})
+	client := &http.Client{Timeout: time.Second}
+
+	steps := []struct {
+		name    string
+		status  int
+		healthy int
+	}{
+		{"first failure", http.StatusInternalServerError, 1},
+		{"second failure", http.StatusBadGateway, 1},
+		{"success resets failures", http.StatusOK, 1},
+		{"failure after reset", http.StatusInternalServerError, 1},
+		{"second failure after reset", http.StatusInternalServerError, 1},
+		{"unhealthy threshold", http.StatusServiceUnavailable, 0},
+		{"first success", http.StatusOK, 0},
+		{"failure resets successes", http.StatusInternalServerError, 0},
+		{"success after reset", http.StatusNoContent, 0},
+		{"healthy threshold", http.StatusOK, 1},
+		{"redirects are healthy", http.StatusFound, 1},
+	}
+
+	for _, step := range steps {
+		atomic.StoreInt32(&status, int32(step.status))
+		h.probe(client, check)
+
+		if got := h.healthy(); got != step.healthy {
+			t.Fatalf("%s: expected %d healthy endpoints, got %d", step.name, step.healthy, got)
+		}
+
+		if got := len(balancer.Servers()); got != step.healthy {
+			t.Fatalf("%s: expected %d balanced endpoints, got %d", step.name, step.healthy, got)
+		}
+	}
+}
+
+func TestCheckDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		check  config.Check
+		global config.Check
+		want   config.Check
+	}{
+		{
+			name: "static defaults",
+			want: config.Check{Interval: 10 * time.Second, Timeout: 2 * time.Second, Healthy: 2, Unhealthy: 3},
+		},
+		{
+			name:   "global defaults",
+			global: config.Check{Path: "/ping", Interval: time.Second, Timeout: time.Second, Healthy: 1, Unhealthy: 1},
+			want:   config.Check{Path: "/ping", Interval: time.Second, Timeout: time.Second, Healthy: 1, Unhealthy: 1},
+		},
+		{
+			name:   "route overrides",
+			check:  config.Check{Path: "/healthz", Unhealthy: 5},
+			global: config.Check{Path: "/ping", Unhealthy: 1},
+			want:   config.Check{Path: "/healthz", Interval: 10 * time.Second, Timeout: 2 * time.Second, Healthy: 2, Unhealthy: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkDefaults(tt.check, tt.global); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
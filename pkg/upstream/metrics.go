@@ -0,0 +1,58 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/webhippie/oauth2-proxy/pkg/metrics"
+)
+
+type attemptsKey struct{}
+
+// transport records latency and status of every request to an endpoint,
+// retries of the buffer get recorded as separate requests.
+type transport struct {
+	route string
+	next  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	endpoint := r.URL.Scheme + "://" + r.URL.Host
+	started := time.Now()
+
+	resp, err := t.next.RoundTrip(r)
+
+	metrics.UpstreamDuration.WithLabelValues(t.route, endpoint).Observe(time.Since(started).Seconds())
+
+	if err != nil {
+		metrics.UpstreamRequests.WithLabelValues(t.route, endpoint, "error").Inc()
+		return resp, err
+	}
+
+	metrics.UpstreamRequests.WithLabelValues(t.route, endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	return resp, nil
+}
+
+// retries counts every attempt of the buffer beyond the first one, the
+// counter gets attached to the request context before the buffer.
+type retries struct {
+	route string
+	next  http.Handler
+}
+
+func (h *retries) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if attempts, ok := r.Context().Value(attemptsKey{}).(*int32); ok {
+		if atomic.AddInt32(attempts, 1) > 1 {
+			metrics.UpstreamRetries.WithLabelValues(h.route).Inc()
+		}
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+func withAttempts(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), attemptsKey{}, new(int32)))
+}
@@ -0,0 +1,319 @@
+package upstream
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vulcand/oxy/buffer"
+	"github.com/vulcand/oxy/forward"
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/webhippie/oauth2-proxy/pkg/config"
+	"github.com/webhippie/oauth2-proxy/pkg/policy"
+)
+
+// Upstream defines a route with its own pool of endpoints and balancer.
+type Upstream struct {
+	Route    config.Route
+	Balancer *roundrobin.RoundRobin
+	Policy   *policy.Policy
+	handler  http.Handler
+	health   *health
+}
+
+// NewUpstream initializes the balancer for a single route.
+func NewUpstream(route config.Route) (*Upstream, error) {
+	fwd, err := forward.New(
+		forward.PassHostHeader(true),
+		forward.RoundTripper(&transport{
+			route: route.Name,
+			next:  http.DefaultTransport,
+		}),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	lb, err := roundrobin.New(fwd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	handler, err := buffer.New(
+		&retries{
+			route: route.Name,
+			next:  lb,
+		},
+		buffer.Retry(`IsNetworkError() && Attempts() < 3`),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	checks := newHealth(lb)
+	checks.configure(route)
+
+	return &Upstream{
+		Route:    route,
+		Balancer: lb,
+		Policy:   policy.New(route.Policy),
+		handler:  handler,
+		health:   checks,
+	}, nil
+}
+
+// update returns a copy of the upstream for the changed route, the balancer
+// and the health checks are shared.
+func (u *Upstream) update(route config.Route) *Upstream {
+	u.health.configure(route)
+
+	return &Upstream{
+		Route:    route,
+		Balancer: u.Balancer,
+		Policy:   policy.New(route.Policy),
+		handler:  u.handler,
+		health:   u.health,
+	}
+}
+
+// Healthy returns the number of healthy endpoints.
+func (u *Upstream) Healthy() int {
+	return u.health.healthy()
+}
+
+// Match checks if the request matches the host and path of the route.
+func (u *Upstream) Match(r *http.Request) bool {
+	return matchHost(u.Route.Host, r.Host) && matchPath(u.Route.Path, r.URL.Path)
+}
+
+// ServeHTTP strips the route prefix if required and forwards the request.
+func (u *Upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if u.Route.Strip && u.Route.Path != "" && u.Route.Path != "/" {
+		prefix := strings.TrimSuffix(u.Route.Path, "/")
+
+		r.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.RawPath, prefix), "/")
+		}
+
+		r.RequestURI = r.URL.RequestURI()
+	}
+
+	u.handler.ServeHTTP(w, withAttempts(r))
+}
+
+// Table defines the routing table to all upstreams.
+type Table struct {
+	upstreams []*Upstream
+	mutex     sync.RWMutex
+}
+
+// New initializes the routing table based on the configuration, the plain
+// list of proxy endpoints gets used as catch-all route.
+func New(cfg *config.Config) (*Table, error) {
+	t := &Table{}
+
+	if err := t.Update(cfg); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Update reconciles the routing table with the configuration. Balancers of
+// routes with an unchanged name are kept, so in-flight requests and the
+// balancer state survive a reload.
+func (t *Table) Update(cfg *config.Config) error {
+	routes := make([]config.Route, 0, len(cfg.Proxy.Routes)+1)
+	routes = append(routes, cfg.Proxy.Routes...)
+
+	if len(cfg.Proxy.Endpoints) > 0 {
+		routes = append(routes, config.Route{
+			Name:      "default",
+			Path:      "/",
+			Endpoints: cfg.Proxy.Endpoints,
+			Policy:    cfg.Proxy.Policy,
+		})
+	}
+
+	existing := map[string]*Upstream{}
+
+	for _, u := range t.Upstreams() {
+		existing[u.Route.Name] = u
+	}
+
+	upstreams := make([]*Upstream, 0, len(routes))
+
+	for i, route := range routes {
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i)
+		}
+
+		if route.Path == "" {
+			route.Path = "/"
+		}
+
+		route.Check = checkDefaults(route.Check, cfg.Proxy.Check)
+
+		if u, ok := existing[route.Name]; ok {
+			upstreams = append(upstreams, u.update(route))
+			delete(existing, route.Name)
+
+			continue
+		}
+
+		u, err := NewUpstream(route)
+
+		if err != nil {
+			return err
+		}
+
+		upstreams = append(upstreams, u)
+	}
+
+	sort.SliceStable(upstreams, func(i, j int) bool {
+		a, b := upstreams[i].Route, upstreams[j].Route
+
+		if (a.Host != "") != (b.Host != "") {
+			return a.Host != ""
+		}
+
+		return len(a.Path) > len(b.Path)
+	})
+
+	for _, u := range existing {
+		u.health.close()
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.upstreams = upstreams
+	return nil
+}
+
+// Close stops the health checks of all upstreams.
+func (t *Table) Close() {
+	for _, u := range t.Upstreams() {
+		u.health.close()
+	}
+}
+
+// Ready checks if any upstream has a healthy endpoint, without any upstream
+// the proxy only serves auth requests and is always ready.
+func (t *Table) Ready() bool {
+	upstreams := t.Upstreams()
+
+	if len(upstreams) == 0 {
+		return true
+	}
+
+	for _, u := range upstreams {
+		if u.Healthy() > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Upstreams returns all upstreams of the routing table.
+func (t *Table) Upstreams() []*Upstream {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.upstreams
+}
+
+// Match returns the upstream matching the request.
+func (t *Table) Match(r *http.Request) *Upstream {
+	for _, u := range t.Upstreams() {
+		if u.Match(r) {
+			return u
+		}
+	}
+
+	return nil
+}
+
+// ServeHTTP forwards the request to the matching upstream.
+func (t *Table) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u := t.Match(r)
+
+	if u == nil {
+		log.Debug().
+			Str("host", r.Host).
+			Str("path", r.URL.Path).
+			Msg("no matching route found")
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadGateway)
+
+		w.Write([]byte(http.StatusText(http.StatusBadGateway)))
+		return
+	}
+
+	u.ServeHTTP(w, r)
+}
+
+func endpoints(route config.Route) []*url.URL {
+	result := make([]*url.URL, 0, len(route.Endpoints))
+
+	for _, endpoint := range route.Endpoints {
+		parsed, err := url.Parse(endpoint)
+
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("route", route.Name).
+				Str("endpoint", endpoint).
+				Msg("failed to parse endpoint")
+
+			continue
+		}
+
+		result = append(result, parsed)
+	}
+
+	return result
+}
+
+func matchHost(pattern, host string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	pattern = strings.ToLower(pattern)
+	host = strings.ToLower(host)
+
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+
+	return pattern == host
+}
+
+func matchPath(prefix, path string) bool {
+	if prefix == "" || prefix == "/" {
+		return true
+	}
+
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/")
+	}
+
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}